@@ -1,57 +1,233 @@
 package main
 
 import (
-	"log"
-
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
 	"TP_Andreev/internal/config"
 	"TP_Andreev/internal/db"
 	"TP_Andreev/internal/db/migrations"
+	"TP_Andreev/internal/logging"
+	"TP_Andreev/internal/metrics"
 	"TP_Andreev/internal/repo/business_trip_repo"
+	"TP_Andreev/internal/repo/data_version_repo"
 	"TP_Andreev/internal/repo/employee_repo"
+	"TP_Andreev/internal/repo/loader_run_repo"
 	"TP_Andreev/internal/service"
 	"TP_Andreev/internal/transport/http/controller/employee_controller"
+	"TP_Andreev/internal/transport/http/controller/health_controller"
 	"TP_Andreev/internal/transport/http/controller/main_controller"
+	"TP_Andreev/internal/transport/http/middleware"
 	"TP_Andreev/internal/transport/http/router"
 )
 
+// version identifies the build; release builds set it with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
 func main() {
-	cfg, err := config.Load()
+	if err := run(); err != nil {
+		slog.Error("app failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the server and blocks until it fails or SIGINT/SIGTERM drains it
+func run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
 	if err != nil {
-		log.Fatalf("congif load failed: %v", err)
+		return fmt.Errorf("config load failed: %w", err)
+	}
+	if *printConfig {
+		return cfg.WriteYAML(os.Stdout)
 	}
 
-	db, err := db.Connect(&cfg.Database)
+	// Initialize logger; the standard log package is routed to it as well
+	logger := logging.New(cfg.Log, os.Stderr)
+	slog.SetDefault(logger)
+	logger.Info("config loaded", "file", cfg.File, "dotenv", cfg.DotEnvLoaded, "database", cfg.Database)
+
+	database, err := db.Connect(ctx, &cfg.Database, logger, cfg.Log.SlowQuery)
 	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func() {
+		if err := db.Close(database); err != nil {
+			logger.Error("failed to close database", "error", err)
+		}
+	}()
+
+	if err := db.Instrument(database); err != nil {
+		return fmt.Errorf("failed to instrument database: %w", err)
 	}
 
-	if err := migrations.Migrate(db); err != nil {
-		log.Fatalf("auto-migrate failed: %v", err)
+	// Refuse to serve a database whose schema does not match this build
+	if err := migrations.Check(ctx, database); err != nil {
+		return fmt.Errorf("database schema mismatch, run migrate up: %w", err)
 	}
 
+	// The loader runs as a separate process, so its runs are read from the database
+	service.RegisterLoaderMetrics(metrics.Default, loader_run_repo.New(database), 2*time.Second, logger)
+
 	service := service.New(
-		employee_repo.New(db),
-		business_trip_repo.New(db),
+		employee_repo.New(database),
+		business_trip_repo.New(database),
+		logger,
 	)
 
 	// Initialize router
 	r := router.New()
+	r.Logger = logger.With("component", "router")
+
+	errorPage := template.Must(template.New("error.html").Funcs(r.FuncMap()).ParseFiles("web/templates/error.html"))
+	r.Use(middleware.Recovery(errorPage, logger))
+
+	// Cancel database queries of slow or abandoned requests
+	r.Use(middleware.Timeout(cfg.Server.RequestTimeout))
+
+	// CORS runs on the root router so it also answers preflight requests
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		r.Use(middleware.CORS(r, middleware.CORSConfig{
+			AllowedOrigins:   cfg.CORS.AllowedOrigins,
+			AllowedMethods:   cfg.CORS.AllowedMethods,
+			AllowedHeaders:   cfg.CORS.AllowedHeaders,
+			ExposedHeaders:   []string{middleware.RequestIDHeader, "Retry-After"},
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			MaxAge:           cfg.CORS.MaxAge,
+		}))
+	}
+
+	// Compress after CORS so preflight responses stay untouched
+	r.Use(middleware.Compress(gzip.DefaultCompression))
 
 	// Initialize controller
-	pageCtrl := main_controller.New(*service)
-	employeeCtrl := employee_controller.New(*service)
+	pageCtrl := main_controller.New(*service, r.FuncMap())
+	employeeCtrl := employee_controller.New(*service, r.FuncMap())
+	healthCtrl := health_controller.New(2*time.Second,
+		health_controller.Check{Name: "database", Fn: func(ctx context.Context) error {
+			return db.Ping(ctx, database)
+		}},
+		health_controller.Check{Name: "migrations", Fn: func(ctx context.Context) error {
+			return migrations.Check(ctx, database)
+		}},
+	)
 
-	// Serve static files
-	fs := http.FileServer(http.Dir("web/static"))
-	http.Handle("/static/", http.StripPrefix("/static/", fs))
+	// Pages hit the database on every request, so they are rate limited per client IP
+	limiter := middleware.NewRateLimiter(ctx, middleware.RateLimitConfig{
+		Rate:  cfg.Server.RateLimit,
+		Burst: cfg.Server.RateBurst,
+	})
+
+	// Pages are revalidated against the data version bumped by the loader
+	versions := data_version_repo.New(database)
+	// The ETag prefix is the same on every replica and across restarts, but
+	// changes when a deploy ships new code or templates
+	templates, err := digest("web/templates")
+	if err != nil {
+		return fmt.Errorf("failed to hash templates: %w", err)
+	}
+	build := version + "." + templates
+	pages := r.Group("/", limiter.Middleware(), middleware.Conditional(build, versions.Current, logger))
 
 	// Register routes
-	r.GET("/", pageCtrl.GetMainPage)
-	r.GET("/employee/:id", employeeCtrl.GetEmployee)
+	pages.GET("/", pageCtrl.GetMainPage).Name("main")
+	pages.GET("/employee/:id{uint}", employeeCtrl.GetEmployee).Name("employee.show")
+
+	// Probes for the container healthcheck and load balancers
+	r.GET("/healthz", healthCtrl.Liveness).Name("healthz")
+	r.GET("/readyz", healthCtrl.Readiness).Name("readyz")
+
+	// Prometheus scrape endpoint
+	r.GET("/metrics", router.WrapHandler(metrics.Default.Handler())).Name("metrics")
+
+	// Serve static files through the router so they share its middleware
+	r.Mount("/static", http.FileServer(http.Dir("web/static")))
+
+	if cfg.Server.Debug {
+		r.GET("/debug/routes", r.DebugRoutesHandler()).Name("debug.routes")
+	}
+
+	for _, route := range r.Routes() {
+		name := route.Name
+		if name == "" {
+			name = "-"
+		}
+		logger.Info("route", "method", route.Method, "pattern", route.Pattern, "name", name, "middlewares", route.Middlewares)
+	}
+
+	// Request ID, access log and metrics wrap the router so they see every request
+	handler := middleware.RequestID(middleware.AccessLog(logger)(middleware.Metrics(r)))
+
+	server := &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           handler,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+
+	// Start server
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("listening", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	// Drain in-flight requests; a second signal kills the process immediately
+	stop()
+	logger.Info("shutting down, draining in-flight requests", "timeout", cfg.Server.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	if err := <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	logger.Info("server stopped")
+	return nil
+}
 
-	// Start server with both router and static handler
-	http.Handle("/", r)
-	http.ListenAndServe(":"+cfg.Server.Port, nil)
+// digest returns a short hash of the names and contents of the files in dir
+func digest(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(path), len(data))
+		h.Write(data)
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil)[:6]), err
 }
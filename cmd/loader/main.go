@@ -1,39 +1,55 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"log"
+	"log/slog"
+	"os"
 
 	"TP_Andreev/internal/config"
 	"TP_Andreev/internal/db"
+	"TP_Andreev/internal/db/migrations"
+	"TP_Andreev/internal/logging"
 	"TP_Andreev/internal/service"
 )
 
 func main() {
 	filePath := flag.String("file", "datasets/employee_travel_data.csv", "Path to the CSV file")
-	flag.Parse()
 
-	// Load configuration
-	cfg, err := config.Load()
+	// Load configuration; this also parses the command line
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
 	if err != nil {
-		log.Fatalf("failed to load config: %v", err)
+		slog.Error("failed to load config", "error", err)
+		os.Exit(1)
 	}
 
+	// Initialize logger
+	logger := logging.New(cfg.Log, os.Stderr)
+	slog.SetDefault(logger)
+
 	// Connect to database
-	database, err := db.Connect(&cfg.Database)
+	database, err := db.Connect(context.Background(), &cfg.Database, logger, cfg.Log.SlowQuery)
 	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+		logger.Error("failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+
+	// Only load into a schema this build knows
+	if err := migrations.Check(context.Background(), database); err != nil {
+		logger.Error("database schema mismatch, run migrate up", "error", err)
+		os.Exit(1)
 	}
 
 	// Create data loader service
-	loaderService := service.NewDataLoaderService(database)
+	loaderService := service.NewDataLoaderService(database, logger)
 
 	// Load data
-	log.Printf("Loading data from %s...", *filePath)
-	err = loaderService.LoadEmployeeTravelData(*filePath)
+	logger.Info("loading data", "file", *filePath)
+	stats, err := loaderService.LoadEmployeeTravelData(*filePath)
 	if err != nil {
-		log.Fatalf("failed to load data: %v", err)
+		logger.Error("failed to load data", "error", err, "processed", stats.Processed, "overwritten", stats.Overwritten, "skipped", stats.Skipped, "failed", stats.Failed)
+		os.Exit(1)
 	}
 
-	log.Println("Data loaded successfully!")
+	logger.Info("data loaded", "processed", stats.Processed, "overwritten", stats.Overwritten, "skipped", stats.Skipped, "failed", stats.Failed)
 }
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"TP_Andreev/internal/config"
+	"TP_Andreev/internal/db"
+	"TP_Andreev/internal/db/migrations"
+	"TP_Andreev/internal/logging"
+)
+
+const usage = `Usage: migrate [flags] <command>
+
+Commands:
+  up             apply all pending migrations
+  down [n|all]   roll back the last n migrations (default 1)
+  status         list migrations and whether they are applied
+  create <name>  write an empty migration pair to -dir
+
+Flags:
+`
+
+var errUsage = errors.New("invalid arguments, see migrate -h")
+
+func main() {
+	if err := run(); err != nil {
+		slog.Error("migrate failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	dir := flag.String("dir", "internal/db/migrations/sql", "directory new migrations are created in")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		return fmt.Errorf("config load failed: %w", err)
+	}
+	args := flag.Args()
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	logger := logging.New(cfg.Log, os.Stderr)
+	slog.SetDefault(logger)
+
+	// Validate arguments before connecting
+	steps := 0
+	switch args[0] {
+	case "create":
+		if len(args) != 2 {
+			return errUsage
+		}
+		up, down, err := migrations.Create(*dir, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Println(up)
+		fmt.Println(down)
+		return nil
+	case "up", "status":
+		if len(args) != 1 {
+			return errUsage
+		}
+	case "down":
+		if steps, err = parseSteps(args[1:]); err != nil {
+			return err
+		}
+	default:
+		return errUsage
+	}
+
+	database, err := db.Connect(ctx, &cfg.Database, logger, cfg.Log.SlowQuery)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func() {
+		if err := db.Close(database); err != nil {
+			logger.Error("failed to close database", "error", err)
+		}
+	}()
+
+	switch args[0] {
+	case "up":
+		n, err := migrations.Up(ctx, database, logger)
+		logger.Info("migrations applied", "count", n)
+		return err
+
+	case "down":
+		n, err := migrations.Down(ctx, database, logger, steps)
+		logger.Info("migrations rolled back", "count", n)
+		return err
+	}
+
+	statuses, err := migrations.List(ctx, database)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		status, appliedAt := "pending", ""
+		if s.Applied {
+			status, appliedAt = "applied", s.AppliedAt.Format(time.RFC3339)
+		}
+		if s.Unknown {
+			status = "unknown"
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, status, appliedAt)
+	}
+	return w.Flush()
+}
+
+// parseSteps parses the optional argument of down: a positive count or "all"
+func parseSteps(args []string) (int, error) {
+	switch {
+	case len(args) == 0:
+		return 1, nil
+	case len(args) > 1:
+		return 0, errUsage
+	case args[0] == "all":
+		return int(^uint(0) >> 1), nil
+	}
+	steps, err := strconv.Atoi(args[0])
+	if err != nil || steps < 1 {
+		return 0, fmt.Errorf("down: %q is not a positive number of migrations", args[0])
+	}
+	return steps, nil
+}
@@ -1,70 +1,188 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
-	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	CORS     CORSConfig     `yaml:"cors"`
+	Log      LogConfig      `yaml:"log"`
+
+	File         string `yaml:"-"` // config file that was read, "" if none
+	DotEnvLoaded bool   `yaml:"-"` // whether a .env file was found
+}
+
+// LogConfig configures the application-wide slog logger
+type LogConfig struct {
+	Level  slog.Level `yaml:"level"`
+	Format string     `yaml:"format"` // "text" or "json"
+
+	SlowQuery time.Duration `yaml:"slow_query"` // SQL queries slower than this are logged as warnings; 0 disables
 }
 
 type ServerConfig struct {
-	Port string
+	Port      string  `yaml:"port"`
+	Debug     bool    `yaml:"debug"`      // enables debug endpoints such as /debug/routes
+	RateLimit float64 `yaml:"rate_limit"` // page requests per second allowed for a single client
+	RateBurst int     `yaml:"rate_burst"` // page requests a client may make in a burst
+
+	RequestTimeout time.Duration `yaml:"request_timeout"` // deadline for handling a single request
+
+	ReadTimeout       time.Duration `yaml:"read_timeout"` // whole request, including the body
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"` // must exceed RequestTimeout
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`  // keep-alive connections
+	MaxHeaderBytes    int           `yaml:"max_header_bytes"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"` // how long to drain in-flight requests
 }
 
-type DatabaseConfig struct {
-	Host     string
-	Port     int
-	User     string
-	Password string
-	Name     string
+// CORSConfig lists cross-origin clients allowed to call the API.
+// CORS is disabled when AllowedOrigins is empty.
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins"`
+	AllowedMethods   []string      `yaml:"allowed_methods"` // empty means every method the route supports
+	AllowedHeaders   []string      `yaml:"allowed_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
 }
 
-// Load reads configuration from environment variables
-func Load() (*Config, error) {
-	if err := godotenv.Load(); err != nil {
-        log.Print("No .env file found")
-    }
+type DatabaseConfig struct {
+	Host         string `yaml:"host"`
+	Port         int    `yaml:"port"`
+	User         string `yaml:"user"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"` // read the password from this file instead, e.g. a Docker secret
+	Name         string `yaml:"name"`
 
-	dbPort, err := strconv.Atoi(getEnv("DB_PORT", "5432"))
-	if err != nil {
-		return nil, fmt.Errorf("invalid DB_PORT: %w", err)
-	}
+	SSLMode     string `yaml:"sslmode"`     // disable, allow, prefer, require, verify-ca or verify-full
+	SSLRootCert string `yaml:"sslrootcert"` // CA certificate used to verify the server
+	SSLCert     string `yaml:"sslcert"`     // client certificate
+	SSLKey      string `yaml:"sslkey"`      // client certificate key
+
+	MaxOpenConns    int           `yaml:"max_open_conns"` // 0 means unlimited
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`  // 0 keeps connections forever
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"` // 0 keeps idle connections forever
+	ConnectTimeout  time.Duration `yaml:"connect_timeout"`    // how long startup retries an unreachable database
+}
 
-	cfg := &Config{
+// Default returns the configuration used when nothing overrides it
+func Default() *Config {
+	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("PORT", "3000"),
+			Port:      "3000",
+			RateLimit: 5,
+			RateBurst: 20,
+
+			RequestTimeout: 10 * time.Second,
+
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+			ShutdownTimeout:   15 * time.Second,
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "postgres"),
-			Port:     dbPort,
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", "postgres"),
-			Name:     getEnv("DB_NAME", "database"),
+			Host:     "postgres",
+			Port:     5432,
+			User:     "postgres",
+			Password: "postgres",
+			Name:     "database",
+			SSLMode:  "disable",
+
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnectTimeout:  30 * time.Second,
+		},
+		CORS: CORSConfig{
+			AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-Token", "X-Request-ID"},
+			MaxAge:         10 * time.Minute,
+		},
+		Log: LogConfig{
+			Level:     slog.LevelInfo,
+			Format:    "text",
+			SlowQuery: 200 * time.Millisecond,
 		},
 	}
-
-	return cfg, nil
 }
 
-// getEnv gets an environment variable with a fallback default value
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// Load builds the configuration in layers: defaults, then the YAML config
+// file (-config flag, CONFIG_FILE or ./config.yaml if present), then
+// environment variables (a .env file is read first), then command-line flags
+// registered on fs. Callers may add their own flags to fs before calling Load.
+// A configured password file takes precedence over the password itself.
+// Every invalid value is reported at once, followed by Validate errors.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	configFile := fs.String("config", "", "path to the YAML config file (env CONFIG_FILE)")
+	var overrides []override
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		record := func(value string) error {
+			overrides = append(overrides, override{setting: s, value: value})
+			return nil
+		}
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		if s.isBool {
+			fs.BoolFunc(s.flag, usage, record)
+		} else {
+			fs.Func(s.flag, usage, record)
+		}
 	}
-	return defaultValue
-}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	cfg.DotEnvLoaded = godotenv.Load() == nil
 
-// DSN returns the database connection string
-func (c *DatabaseConfig) DSN() string {
-	return fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable TimeZone=UTC",
-		c.Host, c.Port, c.User, c.Password, c.Name,
-	)
+	cfg.File = *configFile
+	if cfg.File == "" {
+		cfg.File = os.Getenv("CONFIG_FILE")
+	}
+	if cfg.File == "" {
+		if _, err := os.Stat("config.yaml"); err == nil {
+			cfg.File = "config.yaml"
+		}
+	}
+	if cfg.File != "" {
+		if err := loadFile(cfg, cfg.File); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok && value != "" {
+			if err := s.set(cfg, value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s: %w", s.env, err))
+			}
+		}
+	}
+	for _, o := range overrides {
+		if err := o.set(cfg, o.value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid -%s: %w", o.flag, err))
+		}
+	}
+	if err := cfg.Database.readPasswordFile(); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, cfg.Validate())
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
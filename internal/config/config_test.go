@@ -0,0 +1,166 @@
+package config_test
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"TP_Andreev/internal/config"
+)
+
+func TestLoadLayers(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `
+server:
+  port: "4000"
+  request_timeout: 5s
+database:
+  host: file-host
+  name: file-db
+  password: secret
+cors:
+  allowed_origins: [https://spa.example]
+log:
+  level: warn
+`
+	if err := os.WriteFile(file, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DB_HOST", "env-host")
+	t.Setenv("DB_NAME", "env-db")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := config.Load(fs, []string{"-config", file, "-db-name", "flag-db", "-debug"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Server.Port != "4000" || cfg.Server.RequestTimeout != 5*time.Second {
+		t.Errorf("file values not applied: %+v", cfg.Server)
+	}
+	if cfg.Server.WriteTimeout != 30*time.Second {
+		t.Errorf("default lost: write_timeout = %s", cfg.Server.WriteTimeout)
+	}
+	if cfg.Database.Host != "env-host" {
+		t.Errorf("env must override file, got host %q", cfg.Database.Host)
+	}
+	if cfg.Database.Name != "flag-db" || !cfg.Server.Debug {
+		t.Errorf("flags must override env, got name %q debug %v", cfg.Database.Name, cfg.Server.Debug)
+	}
+	if cfg.Log.Level.String() != "WARN" || len(cfg.CORS.AllowedOrigins) != 1 {
+		t.Errorf("unexpected log/cors: %+v %+v", cfg.Log, cfg.CORS)
+	}
+
+	var b strings.Builder
+	if err := cfg.WriteYAML(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "secret") || !strings.Contains(b.String(), "password: REDACTED") {
+		t.Errorf("password not redacted:\n%s", b.String())
+	}
+	if cfg.Database.Password != "secret" {
+		t.Errorf("redaction must not modify the config")
+	}
+}
+
+func TestLoadReportsAllErrors(t *testing.T) {
+	t.Setenv("DB_PORT", "x")
+	t.Setenv("LOG_FORMAT", "xml")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := config.Load(fs, []string{"-rate-burst", "0", "-write-timeout", "1s"})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, want := range []string{"DB_PORT", "log.format", "server.rate_burst", "server.write_timeout"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadRejectsWildcardOriginWithCredentials(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "*")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := config.Load(fs, nil); err == nil || !strings.Contains(err.Error(), "cors.allowed_origins") {
+		t.Errorf("Expected cors.allowed_origins error, got: %v", err)
+	}
+}
+
+func TestLoadRejectsUnknownFileKeys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte("server:\n  prot: \"80\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", file)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := config.Load(fs, nil); err == nil || !strings.Contains(err.Error(), "prot") {
+		t.Errorf("Expected unknown key error, got: %v", err)
+	}
+}
+
+func TestDSNQuotesValuesAndStringRedacts(t *testing.T) {
+	cfg := config.DatabaseConfig{
+		Host:        "db",
+		Port:        5432,
+		User:        "app",
+		Password:    `it's a \secret`,
+		Name:        "trips",
+		SSLMode:     "verify-full",
+		SSLRootCert: "/certs/ca file.pem",
+	}
+
+	want := `host=db port=5432 user=app password='it\'s a \\secret' dbname=trips sslmode=verify-full sslrootcert='/certs/ca file.pem' TimeZone=UTC`
+	if got := cfg.DSN(); got != want {
+		t.Errorf("DSN() = %s\nwant     %s", got, want)
+	}
+
+	for _, s := range []string{cfg.String(), fmt.Sprint(&cfg), fmt.Sprintf("%+v", config.Config{Database: cfg})} {
+		if strings.Contains(s, "secret") || !strings.Contains(s, "password=REDACTED") {
+			t.Errorf("password leaked: %s", s)
+		}
+	}
+}
+
+func TestLogsRedactPassword(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.Password = "s3cret"
+
+	var out strings.Builder
+	for _, h := range []slog.Handler{slog.NewJSONHandler(&out, nil), slog.NewTextHandler(&out, nil)} {
+		slog.New(h).Info("config loaded", "database", cfg.Database, "config", cfg)
+	}
+	if strings.Contains(out.String(), "s3cret") || !strings.Contains(out.String(), "password=REDACTED") {
+		t.Errorf("password leaked into logs:\n%s", out.String())
+	}
+}
+
+func TestLoadPasswordFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "db_password")
+	if err := os.WriteFile(file, []byte("from file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DB_PASSWORD", "from env")
+	t.Setenv("DB_PASSWORD_FILE", file)
+
+	cfg, err := config.Load(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Password != "from file" {
+		t.Errorf("Password = %q, want the file contents", cfg.Database.Password)
+	}
+
+	t.Setenv("DB_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := config.Load(flag.NewFlagSet("test", flag.ContinueOnError), nil); err == nil || !strings.Contains(err.Error(), "password_file") {
+		t.Errorf("Expected password_file error, got: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// sslModes are the sslmode values understood by libpq and pgx
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// DSN returns the database connection string. Values are quoted as libpq
+// requires, so passwords may contain spaces, quotes and backslashes.
+func (c *DatabaseConfig) DSN() string {
+	return c.dsn(c.Password)
+}
+
+// String returns the connection string with the password redacted, so the
+// config can be logged or printed safely.
+func (c DatabaseConfig) String() string {
+	password := c.Password
+	if password != "" {
+		password = redacted
+	}
+	return c.dsn(password)
+}
+
+// LogValue keeps the password out of structured logs; the JSON handler
+// would otherwise encode every field and never call String.
+func (c DatabaseConfig) LogValue() slog.Value {
+	return slog.StringValue(c.String())
+}
+
+// MarshalJSON encodes the redacted connection string, so the password does
+// not leak when a Config containing it is logged as JSON.
+func (c DatabaseConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *DatabaseConfig) dsn(password string) string {
+	var b strings.Builder
+	add := func(key, value string) {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(quoteDSNValue(value))
+	}
+	add("host", c.Host)
+	add("port", fmt.Sprint(c.Port))
+	add("user", c.User)
+	add("password", password)
+	add("dbname", c.Name)
+	add("sslmode", c.SSLMode)
+	for _, opt := range []struct{ key, value string }{
+		{"sslrootcert", c.SSLRootCert},
+		{"sslcert", c.SSLCert},
+		{"sslkey", c.SSLKey},
+	} {
+		if opt.value != "" {
+			add(opt.key, opt.value)
+		}
+	}
+	add("TimeZone", "UTC")
+	return b.String()
+}
+
+// quoteDSNValue single-quotes a key/value DSN value when it is empty or
+// contains whitespace, quotes or backslashes, escaping the latter two.
+func quoteDSNValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\r\f\v'\\") {
+		return value
+	}
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + value + "'"
+}
+
+// readPasswordFile replaces Password with the contents of PasswordFile, as
+// used with Docker secrets. A single trailing newline is ignored.
+func (c *DatabaseConfig) readPasswordFile() error {
+	if c.PasswordFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.PasswordFile)
+	if err != nil {
+		return fmt.Errorf("database.password_file: %w", err)
+	}
+	password := strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+	if password == "" {
+		return fmt.Errorf("database.password_file: %s is empty", c.PasswordFile)
+	}
+	c.Password = password
+	return nil
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// setting binds one configuration field to its environment variable and
+// command-line flag. Secrets have no flag: command lines are visible in ps.
+type setting struct {
+	env    string
+	flag   string
+	usage  string
+	isBool bool
+	set    func(cfg *Config, value string) error
+}
+
+// override is a flag value applied after the environment
+type override struct {
+	setting
+	value string
+}
+
+var settings = []setting{
+	stringVar("PORT", "port", "HTTP server port", func(c *Config) *string { return &c.Server.Port }),
+	boolVar("DEBUG", "debug", "enable debug endpoints such as /debug/routes", func(c *Config) *bool { return &c.Server.Debug }),
+	floatVar("RATE_LIMIT_RPS", "rate-limit", "page requests per second allowed per client", func(c *Config) *float64 { return &c.Server.RateLimit }),
+	intVar("RATE_LIMIT_BURST", "rate-burst", "page requests a client may burst", func(c *Config) *int { return &c.Server.RateBurst }),
+	durationVar("REQUEST_TIMEOUT", "request-timeout", "deadline for handling a request", func(c *Config) *time.Duration { return &c.Server.RequestTimeout }),
+	durationVar("READ_TIMEOUT", "read-timeout", "time allowed to read a request", func(c *Config) *time.Duration { return &c.Server.ReadTimeout }),
+	durationVar("READ_HEADER_TIMEOUT", "read-header-timeout", "time allowed to read request headers", func(c *Config) *time.Duration { return &c.Server.ReadHeaderTimeout }),
+	durationVar("WRITE_TIMEOUT", "write-timeout", "time allowed to write a response", func(c *Config) *time.Duration { return &c.Server.WriteTimeout }),
+	durationVar("IDLE_TIMEOUT", "idle-timeout", "keep-alive timeout for idle connections", func(c *Config) *time.Duration { return &c.Server.IdleTimeout }),
+	intVar("MAX_HEADER_BYTES", "max-header-bytes", "maximum size of request headers", func(c *Config) *int { return &c.Server.MaxHeaderBytes }),
+	durationVar("SHUTDOWN_TIMEOUT", "shutdown-timeout", "time to drain in-flight requests on shutdown", func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout }),
+
+	stringVar("DB_HOST", "db-host", "PostgreSQL host", func(c *Config) *string { return &c.Database.Host }),
+	intVar("DB_PORT", "db-port", "PostgreSQL port", func(c *Config) *int { return &c.Database.Port }),
+	stringVar("DB_USER", "db-user", "database user", func(c *Config) *string { return &c.Database.User }),
+	stringVar("DB_PASSWORD", "", "database password", func(c *Config) *string { return &c.Database.Password }),
+	stringVar("DB_PASSWORD_FILE", "db-password-file", "file containing the database password", func(c *Config) *string { return &c.Database.PasswordFile }),
+	stringVar("DB_NAME", "db-name", "database name", func(c *Config) *string { return &c.Database.Name }),
+	stringVar("DB_SSLMODE", "db-sslmode", "sslmode: disable, allow, prefer, require, verify-ca or verify-full", func(c *Config) *string { return &c.Database.SSLMode }),
+	stringVar("DB_SSLROOTCERT", "db-sslrootcert", "CA certificate used to verify the database server", func(c *Config) *string { return &c.Database.SSLRootCert }),
+	stringVar("DB_SSLCERT", "db-sslcert", "client certificate for the database", func(c *Config) *string { return &c.Database.SSLCert }),
+	stringVar("DB_SSLKEY", "db-sslkey", "client certificate key for the database", func(c *Config) *string { return &c.Database.SSLKey }),
+	intVar("DB_MAX_OPEN_CONNS", "db-max-open-conns", "maximum open database connections, 0 for unlimited", func(c *Config) *int { return &c.Database.MaxOpenConns }),
+	intVar("DB_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum idle database connections", func(c *Config) *int { return &c.Database.MaxIdleConns }),
+	durationVar("DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "close database connections older than this, 0 disables", func(c *Config) *time.Duration { return &c.Database.ConnMaxLifetime }),
+	durationVar("DB_CONN_MAX_IDLE_TIME", "db-conn-max-idle-time", "close database connections idle longer than this, 0 disables", func(c *Config) *time.Duration { return &c.Database.ConnMaxIdleTime }),
+	durationVar("DB_CONNECT_TIMEOUT", "db-connect-timeout", "how long to retry connecting to the database on startup", func(c *Config) *time.Duration { return &c.Database.ConnectTimeout }),
+	durationVar("DB_SLOW_QUERY", "db-slow-query", "log SQL queries slower than this as warnings, 0 disables", func(c *Config) *time.Duration { return &c.Log.SlowQuery }),
+
+	levelVar("LOG_LEVEL", "log-level", "minimum log level: debug, info, warn or error", func(c *Config) *slog.Level { return &c.Log.Level }),
+	stringVar("LOG_FORMAT", "log-format", "log format: text or json", func(c *Config) *string { return &c.Log.Format }),
+
+	listVar("CORS_ALLOWED_ORIGINS", "cors-allowed-origins", "comma-separated origins allowed cross-origin, * for any", func(c *Config) *[]string { return &c.CORS.AllowedOrigins }),
+	listVar("CORS_ALLOWED_METHODS", "cors-allowed-methods", "comma-separated methods allowed cross-origin", func(c *Config) *[]string { return &c.CORS.AllowedMethods }),
+	listVar("CORS_ALLOWED_HEADERS", "cors-allowed-headers", "comma-separated request headers allowed cross-origin", func(c *Config) *[]string { return &c.CORS.AllowedHeaders }),
+	boolVar("CORS_ALLOW_CREDENTIALS", "cors-allow-credentials", "allow credentials on cross-origin requests", func(c *Config) *bool { return &c.CORS.AllowCredentials }),
+	durationVar("CORS_MAX_AGE", "cors-max-age", "how long browsers may cache preflight responses", func(c *Config) *time.Duration { return &c.CORS.MaxAge }),
+}
+
+func stringVar(env, flag, usage string, field func(*Config) *string) setting {
+	return setting{env: env, flag: flag, usage: usage, set: func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}}
+}
+
+func boolVar(env, flag, usage string, field func(*Config) *bool) setting {
+	return setting{env: env, flag: flag, usage: usage, isBool: true, set: func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}}
+}
+
+func intVar(env, flag, usage string, field func(*Config) *int) setting {
+	return setting{env: env, flag: flag, usage: usage, set: func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}}
+}
+
+func floatVar(env, flag, usage string, field func(*Config) *float64) setting {
+	return setting{env: env, flag: flag, usage: usage, set: func(c *Config, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = f
+		return nil
+	}}
+}
+
+func durationVar(env, flag, usage string, field func(*Config) *time.Duration) setting {
+	return setting{env: env, flag: flag, usage: usage, set: func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = d
+		return nil
+	}}
+}
+
+func levelVar(env, flag, usage string, field func(*Config) *slog.Level) setting {
+	return setting{env: env, flag: flag, usage: usage, set: func(c *Config, v string) error {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			return err
+		}
+		*field(c) = level
+		return nil
+	}}
+}
+
+func listVar(env, flag, usage string, field func(*Config) *[]string) setting {
+	return setting{env: env, flag: flag, usage: usage, set: func(c *Config, v string) error {
+		*field(c) = splitList(v)
+		return nil
+	}}
+}
+
+// loadFile overlays cfg with the YAML file at path. Unknown keys are errors,
+// so typos do not silently fall back to defaults.
+func loadFile(cfg *Config, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "REDACTED"
+
+// Validate checks every field and reports all problems at once
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.Server.Port)
+	check(err == nil && port > 0 && port < 65536, "server.port: %q is not a valid port", c.Server.Port)
+	check(c.Server.RateLimit > 0, "server.rate_limit: must be positive, got %v", c.Server.RateLimit)
+	check(c.Server.RateBurst >= 1, "server.rate_burst: must be at least 1, got %d", c.Server.RateBurst)
+	check(c.Server.RequestTimeout > 0, "server.request_timeout: must be positive, got %s", c.Server.RequestTimeout)
+	check(c.Server.ReadTimeout > 0, "server.read_timeout: must be positive, got %s", c.Server.ReadTimeout)
+	check(c.Server.ReadHeaderTimeout > 0, "server.read_header_timeout: must be positive, got %s", c.Server.ReadHeaderTimeout)
+	check(c.Server.WriteTimeout > c.Server.RequestTimeout, "server.write_timeout: must exceed request_timeout (%s), got %s", c.Server.RequestTimeout, c.Server.WriteTimeout)
+	check(c.Server.IdleTimeout > 0, "server.idle_timeout: must be positive, got %s", c.Server.IdleTimeout)
+	check(c.Server.MaxHeaderBytes > 0, "server.max_header_bytes: must be positive, got %d", c.Server.MaxHeaderBytes)
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout: must be positive, got %s", c.Server.ShutdownTimeout)
+
+	check(c.Database.Host != "", "database.host: must not be empty")
+	check(c.Database.Port > 0 && c.Database.Port < 65536, "database.port: %d is not a valid port", c.Database.Port)
+	check(c.Database.User != "", "database.user: must not be empty")
+	check(c.Database.Name != "", "database.name: must not be empty")
+	check(slices.Contains(sslModes, c.Database.SSLMode), "database.sslmode: %q, want one of %s", c.Database.SSLMode, strings.Join(sslModes, ", "))
+	check((c.Database.SSLCert == "") == (c.Database.SSLKey == ""), "database.sslcert and database.sslkey: must be set together")
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns: must not be negative, got %d", c.Database.MaxOpenConns)
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns: must not be negative, got %d", c.Database.MaxIdleConns)
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns, "database.max_idle_conns: must not exceed max_open_conns (%d), got %d", c.Database.MaxOpenConns, c.Database.MaxIdleConns)
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime: must not be negative, got %s", c.Database.ConnMaxLifetime)
+	check(c.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time: must not be negative, got %s", c.Database.ConnMaxIdleTime)
+	check(c.Database.ConnectTimeout > 0, "database.connect_timeout: must be positive, got %s", c.Database.ConnectTimeout)
+
+	check(!c.CORS.AllowCredentials || !slices.Contains(c.CORS.AllowedOrigins, "*"), "cors.allowed_origins: * cannot be combined with allow_credentials, list the origins explicitly")
+	check(c.CORS.MaxAge >= 0, "cors.max_age: must not be negative, got %s", c.CORS.MaxAge)
+
+	check(c.Log.Format == "text" || c.Log.Format == "json", "log.format: %q, want text or json", c.Log.Format)
+	check(c.Log.SlowQuery >= 0, "log.slow_query: must not be negative, got %s", c.Log.SlowQuery)
+
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of the config with secrets replaced
+func (c Config) Redacted() Config {
+	if c.Database.Password != "" {
+		c.Database.Password = redacted
+	}
+	return c
+}
+
+// WriteYAML writes the effective config as YAML with secrets redacted
+func (c *Config) WriteYAML(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -1,15 +1,101 @@
 package db
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
 	"TP_Andreev/internal/config"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func Connect(cfg *config.DatabaseConfig) (*gorm.DB, error) {
-	dsn := cfg.DSN()
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// Backoff between connection attempts: it doubles from retryMin up to
+// retryMax, and each delay is randomized to half–full length so that
+// replicas restarting together do not retry in lockstep.
+const (
+	retryMin   = 250 * time.Millisecond
+	retryMax   = 5 * time.Second
+	attemptMax = 5 * time.Second // a single attempt never waits longer than this
+)
+
+// Connect opens the database and configures its connection pool; gorm logs
+// through logger, with queries slower than slowQuery reported as warnings.
+// Until cfg.ConnectTimeout has passed, an unreachable database is retried
+// with exponential backoff, so the app can start before Postgres is ready.
+func Connect(ctx context.Context, cfg *config.DatabaseConfig, logger *slog.Logger, slowQuery time.Duration) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{
+		Logger:               newGormLogger(logger, slowQuery),
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if err := waitReady(ctx, db, logger, cfg.ConnectTimeout); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// waitReady pings db until it answers, ctx is done or timeout has passed
+func waitReady(ctx context.Context, db *gorm.DB, logger *slog.Logger, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := retryMin
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancelAttempt := context.WithTimeout(ctx, attemptMax)
+		err := Ping(attemptCtx, db)
+		cancelAttempt()
+		if err == nil {
+			logger.Info("connected to database", "attempts", attempt)
+			return nil
+		}
+
+		delay := backoff/2 + rand.N(backoff/2+1)
+		deadline, _ := ctx.Deadline()
+		if ctx.Err() != nil || time.Until(deadline) < delay {
+			return fmt.Errorf("database not ready after %d attempts: %w", attempt, err)
+		}
+		logger.Warn("database not ready, retrying", "attempt", attempt, "retry_in", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database not ready after %d attempts: %w", attempt, err)
+		case <-time.After(delay):
+		}
+		backoff = min(backoff*2, retryMax)
+	}
+}
+
+// Close closes the connection pool behind db
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
 
-	return db, err
+// Ping checks that the connection pool behind db can reach the database
+func Ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
 }
@@ -0,0 +1,68 @@
+package db_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"TP_Andreev/internal/config"
+	"TP_Andreev/internal/db"
+)
+
+// closedPort returns a local port nothing listens on
+func closedPort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	return port
+}
+
+func TestConnectRetriesUntilTimeout(t *testing.T) {
+	cfg := config.Default().Database
+	cfg.Host = "127.0.0.1"
+	cfg.Port = closedPort(t)
+	cfg.ConnectTimeout = 1500 * time.Millisecond
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	start := time.Now()
+	_, err := db.Connect(context.Background(), &cfg, logger, 0)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*cfg.ConnectTimeout {
+		t.Errorf("Connect took %s, want about %s", elapsed, cfg.ConnectTimeout)
+	}
+	if !strings.Contains(err.Error(), "attempts") {
+		t.Errorf("error does not report attempts: %v", err)
+	}
+	if n := strings.Count(logs.String(), "database not ready, retrying"); n < 2 {
+		t.Errorf("Expected at least 2 retries to be logged, got %d:\n%s", n, logs.String())
+	}
+}
+
+func TestConnectStopsWhenContextIsCancelled(t *testing.T) {
+	cfg := config.Default().Database
+	cfg.Host = "127.0.0.1"
+	cfg.Port = closedPort(t)
+	cfg.ConnectTimeout = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := db.Connect(ctx, &cfg, slog.New(slog.DiscardHandler), 0); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Connect ignored cancellation, took %s", elapsed)
+	}
+}
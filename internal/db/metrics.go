@@ -0,0 +1,97 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"TP_Andreev/internal/metrics"
+
+	"gorm.io/gorm"
+)
+
+const startKey = "metrics:start"
+
+// Instrument records every gorm query in metrics.DBQueries and
+// metrics.DBQueryDuration and exposes the connection pool stats of db.
+// It must be called once per process.
+func Instrument(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := errors.Join(
+		cb.Create().Before("gorm:create").Register("metrics:before_create", before),
+		cb.Create().After("gorm:create").Register("metrics:after_create", after("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", before),
+		cb.Query().After("gorm:query").Register("metrics:after_query", after("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", before),
+		cb.Update().After("gorm:update").Register("metrics:after_update", after("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", before),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", after("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", before),
+		cb.Row().After("gorm:row").Register("metrics:after_row", after("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", before),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", after("raw")),
+	); err != nil {
+		return err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	gauge := func(name, help string, fn func() float64) {
+		metrics.Default.GaugeFunc(name, help, fn)
+	}
+	counter := func(name, help string, fn func() float64) {
+		metrics.Default.CounterFunc(name, help, fn)
+	}
+	gauge("db_pool_max_open_connections", "Maximum number of open connections to the database.", func() float64 {
+		return float64(sqlDB.Stats().MaxOpenConnections)
+	})
+	gauge("db_pool_open_connections", "Established connections, both in use and idle.", func() float64 {
+		return float64(sqlDB.Stats().OpenConnections)
+	})
+	gauge("db_pool_in_use_connections", "Connections currently in use.", func() float64 {
+		return float64(sqlDB.Stats().InUse)
+	})
+	gauge("db_pool_idle_connections", "Idle connections.", func() float64 {
+		return float64(sqlDB.Stats().Idle)
+	})
+	counter("db_pool_wait_count_total", "Connections waited for.", func() float64 {
+		return float64(sqlDB.Stats().WaitCount)
+	})
+	counter("db_pool_wait_duration_seconds_total", "Time blocked waiting for a new connection.", func() float64 {
+		return sqlDB.Stats().WaitDuration.Seconds()
+	})
+	counter("db_pool_max_idle_closed_total", "Connections closed due to SetMaxIdleConns.", func() float64 {
+		return float64(sqlDB.Stats().MaxIdleClosed)
+	})
+	counter("db_pool_max_idle_time_closed_total", "Connections closed due to SetConnMaxIdleTime.", func() float64 {
+		return float64(sqlDB.Stats().MaxIdleTimeClosed)
+	})
+	counter("db_pool_max_lifetime_closed_total", "Connections closed due to SetConnMaxLifetime.", func() float64 {
+		return float64(sqlDB.Stats().MaxLifetimeClosed)
+	})
+	return nil
+}
+
+func before(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func after(op string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		start, ok := db.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		status := "ok"
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			status = "error"
+		}
+		metrics.DBQueries.With(op, table, status).Inc()
+		metrics.DBQueryDuration.With(op, table).Observe(time.Since(start.(time.Time)).Seconds())
+	}
+}
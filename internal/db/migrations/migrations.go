@@ -1,12 +1,326 @@
 package migrations
 
 import (
+	"cmp"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
 	"TP_Andreev/internal/models"
 
 	"gorm.io/gorm"
 )
 
-func Migrate(db *gorm.DB) error {
-	err := db.AutoMigrate(&models.Employee{}, &models.BusinessTrip{}, &models.AssignmentToTrip{})
-	return err
-}
\ No newline at end of file
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockID is the Postgres advisory lock held while migrations run, so
+// replicas starting together do not apply the same migration twice.
+const lockID int64 = 0x54505f4d49475241
+
+// schema lists the models whose tables the migrations must provide
+var schema = []any{&models.Employee{}, &models.BusinessTrip{}, &models.AssignmentToTrip{}, &models.DataVersion{}, &models.LoaderRun{}}
+
+// Migration is a numbered schema change read from NNNN_name.up.sql and its
+// optional rollback NNNN_name.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes a migration and whether the database has applied it
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Unknown   bool // applied to the database but missing from this build
+}
+
+// schemaMigration is a row of schema_migrations, one per applied migration
+type schemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"type:text;not null"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+const createTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    bigint PRIMARY KEY,
+	name       text NOT NULL,
+	applied_at timestamptz NOT NULL
+)`
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// All returns the migrations embedded in the binary ordered by version
+func All() ([]Migration, error) {
+	return load(files, "sql")
+}
+
+// load reads the migrations in dir of fsys, ignoring files other than .sql
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: name must look like 0001_create_table.up.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if version == 0 {
+			return nil, fmt.Errorf("migration %s: version must start at 1", entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d: conflicting names %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %s: up migration is missing or empty", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	return migrations, nil
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Up applies every pending migration in order, each in its own transaction
+// together with its schema_migrations row, and returns how many it applied.
+func Up(ctx context.Context, db *gorm.DB, logger *slog.Logger) (int, error) {
+	all, err := All()
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	err = withLock(ctx, db, logger, func(conn *gorm.DB) error {
+		if err := conn.Exec(createTable).Error; err != nil {
+			return err
+		}
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for version := range done {
+			if !slices.ContainsFunc(all, func(m Migration) bool { return m.Version == version }) {
+				return fmt.Errorf("database has migration %d, which this build does not know", version)
+			}
+		}
+
+		for _, m := range all {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			start := time.Now()
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if _, err := tx.Statement.ConnPool.ExecContext(ctx, m.Up); err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", m, err)
+			}
+			logger.Info("applied migration", "migration", m.String(), "duration", time.Since(start))
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the last steps applied migrations, newest first, and
+// returns how many it rolled back.
+func Down(ctx context.Context, db *gorm.DB, logger *slog.Logger, steps int) (int, error) {
+	all, err := All()
+	if err != nil {
+		return 0, err
+	}
+
+	rolledBack := 0
+	err = withLock(ctx, db, logger, func(conn *gorm.DB) error {
+		if err := conn.Exec(createTable).Error; err != nil {
+			return err
+		}
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		versions := slices.Sorted(maps.Keys(done))
+		slices.Reverse(versions)
+
+		for _, version := range versions[:min(steps, len(versions))] {
+			i := slices.IndexFunc(all, func(m Migration) bool { return m.Version == version })
+			if i < 0 {
+				return fmt.Errorf("database has migration %d, which this build does not know", version)
+			}
+			m := all[i]
+			if strings.TrimSpace(m.Down) == "" {
+				return fmt.Errorf("migration %s has no down migration", m)
+			}
+			start := time.Now()
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if _, err := tx.Statement.ConnPool.ExecContext(ctx, m.Down); err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{Version: m.Version}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", m, err)
+			}
+			logger.Info("rolled back migration", "migration", m.String(), "duration", time.Since(start))
+			rolledBack++
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// List returns the status of every known migration and every applied one,
+// ordered by version.
+func List(ctx context.Context, db *gorm.DB) ([]Status, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	done, err := appliedVersions(db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(all))
+	for _, m := range all {
+		row, ok := done[m.Version]
+		statuses = append(statuses, Status{Version: m.Version, Name: m.Name, Applied: ok, AppliedAt: row.AppliedAt})
+		delete(done, m.Version)
+	}
+	for _, row := range done {
+		statuses = append(statuses, Status{Version: row.Version, Name: row.Name, Applied: true, AppliedAt: row.AppliedAt, Unknown: true})
+	}
+	slices.SortFunc(statuses, func(a, b Status) int { return cmp.Compare(a.Version, b.Version) })
+	return statuses, nil
+}
+
+// Check reports whether the database schema matches this build: every
+// embedded migration and no other must be applied, and every table, column
+// and index of the models must exist.
+func Check(ctx context.Context, db *gorm.DB) error {
+	db = db.WithContext(ctx)
+	statuses, err := List(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		switch {
+		case s.Unknown:
+			return fmt.Errorf("database has migration %d, which this build does not know", s.Version)
+		case !s.Applied:
+			return fmt.Errorf("migration %04d_%s is not applied", s.Version, s.Name)
+		}
+	}
+
+	migrator := db.Migrator()
+	for _, model := range schema {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		if !migrator.HasTable(model) {
+			return fmt.Errorf("table %s is missing", stmt.Schema.Table)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !migrator.HasColumn(model, field.DBName) {
+				return fmt.Errorf("column %s.%s is missing", stmt.Schema.Table, field.DBName)
+			}
+		}
+		for _, index := range stmt.Schema.ParseIndexes() {
+			if !migrator.HasIndex(model, index.Name) {
+				return fmt.Errorf("index %s on %s is missing", index.Name, stmt.Schema.Table)
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// appliedVersions returns the schema_migrations rows by version; none if
+// the table does not exist yet.
+func appliedVersions(db *gorm.DB) (map[int]schemaMigration, error) {
+	done := map[int]schemaMigration{}
+	if !db.Migrator().HasTable(&schemaMigration{}) {
+		return done, db.Statement.Context.Err()
+	}
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		done[row.Version] = row
+	}
+	return done, nil
+}
+
+// withLock runs fn on a single connection holding the migration advisory
+// lock, waiting for other migrators to finish first.
+func withLock(ctx context.Context, db *gorm.DB, logger *slog.Logger, fn func(conn *gorm.DB) error) error {
+	return db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		var locked bool
+		if err := conn.Raw("SELECT pg_try_advisory_lock(?)", lockID).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			logger.Info("waiting for another migration to finish")
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", lockID).Error; err != nil {
+				return err
+			}
+		}
+		defer func() {
+			// Unlock even if ctx was cancelled, as the connection goes back to the pool
+			if err := conn.WithContext(context.WithoutCancel(ctx)).Exec("SELECT pg_advisory_unlock(?)", lockID).Error; err != nil {
+				logger.Error("failed to release migration lock", "error", err)
+			}
+		}()
+		return fn(conn)
+	})
+}
@@ -0,0 +1,90 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	all, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) == 0 || all[0].Version != 1 {
+		t.Fatalf("Expected migrations starting at version 1, got %v", all)
+	}
+	for i, m := range all {
+		if m.Version != i+1 {
+			t.Errorf("migration %s: versions must be consecutive", m)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %s has no down migration", m)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_add_index.up.sql":   {Data: []byte("CREATE INDEX i ON t (c);")},
+		"m/0001_create_t.up.sql":    {Data: []byte("CREATE TABLE t (c int);")},
+		"m/0001_create_t.down.sql":  {Data: []byte("DROP TABLE t;")},
+		"m/README.md":               {Data: []byte("ignored")},
+		"m/0003_skipped.up.sql.bak": {Data: []byte("ignored")},
+	}
+	all, err := load(fsys, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].String() != "0001_create_t" || all[1].String() != "0002_add_index" {
+		t.Fatalf("unexpected migrations: %v", all)
+	}
+	if all[0].Down != "DROP TABLE t;" || all[1].Down != "" {
+		t.Errorf("down migrations not matched to their versions: %+v", all)
+	}
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"bad name":      {"m/1-create.up.sql": {Data: []byte("SELECT 1;")}},
+		"zero version":  {"m/0000_init.up.sql": {Data: []byte("SELECT 1;")}},
+		"missing up":    {"m/0001_init.down.sql": {Data: []byte("SELECT 1;")}},
+		"empty up":      {"m/0001_init.up.sql": {Data: []byte(" \n")}},
+		"name conflict": {"m/0001_a.up.sql": {Data: []byte("SELECT 1;")}, "m/0001_b.down.sql": {Data: []byte("SELECT 1;")}},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := load(fsys, "m"); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "0007_old.up.sql"), []byte("SELECT 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	up, down, err := Create(dir, "Add unique indexes!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(up) != "0008_add_unique_indexes.up.sql" || filepath.Base(down) != "0008_add_unique_indexes.down.sql" {
+		t.Errorf("unexpected files: %s, %s", up, down)
+	}
+	all, err := load(os.DirFS(dir), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected the new migration to load, got %v", all)
+	}
+
+	if _, _, err := Create(dir, "!!!"); err == nil {
+		t.Error("Expected an error for a name without letters or digits")
+	}
+}
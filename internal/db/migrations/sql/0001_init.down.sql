@@ -0,0 +1 @@
+DROP TABLE IF EXISTS data_versions, assignment_to_trips, business_trips, employees;
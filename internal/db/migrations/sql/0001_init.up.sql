@@ -0,0 +1,30 @@
+-- Baseline schema. IF NOT EXISTS lets databases created by the former gorm
+-- AutoMigrate adopt versioned migrations unchanged.
+CREATE TABLE IF NOT EXISTS employees (
+    id   bigserial PRIMARY KEY,
+    name text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS business_trips (
+    id          bigserial PRIMARY KEY,
+    destination text NOT NULL,
+    start_at    date NOT NULL,
+    end_at      date NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS assignment_to_trips (
+    id               bigserial PRIMARY KEY,
+    money_spent      bigint NOT NULL,
+    employee_id      bigint,
+    business_trip_id bigint,
+    CONSTRAINT fk_assignment_to_trips_employee FOREIGN KEY (employee_id)
+        REFERENCES employees (id) ON UPDATE CASCADE ON DELETE SET NULL,
+    CONSTRAINT fk_assignment_to_trips_business_trip FOREIGN KEY (business_trip_id)
+        REFERENCES business_trips (id) ON UPDATE CASCADE ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS data_versions (
+    name       text PRIMARY KEY,
+    version    bigint NOT NULL DEFAULT 0,
+    updated_at timestamptz NOT NULL
+);
@@ -0,0 +1,2 @@
+-- Merged duplicates are not restored.
+DROP INDEX IF EXISTS idx_assignment_to_trips_employee_trip, idx_business_trips_identity, idx_employees_name;
@@ -0,0 +1,45 @@
+-- Re-running the loader used to duplicate rows. Merge the duplicates, then
+-- enforce the natural keys the loader upserts on.
+
+-- Point assignments at the oldest employee with the same name
+UPDATE assignment_to_trips a
+SET employee_id = keep.id
+FROM employees e
+JOIN (SELECT name, min(id) AS id FROM employees GROUP BY name) keep ON keep.name = e.name
+WHERE a.employee_id = e.id AND e.id <> keep.id;
+
+DELETE FROM employees e
+USING employees keep
+WHERE keep.name = e.name AND keep.id < e.id;
+
+-- Point assignments at the oldest trip with the same destination and dates
+UPDATE assignment_to_trips a
+SET business_trip_id = keep.id
+FROM business_trips t
+JOIN (
+    SELECT destination, start_at, end_at, min(id) AS id
+    FROM business_trips
+    GROUP BY destination, start_at, end_at
+) keep ON keep.destination = t.destination AND keep.start_at = t.start_at AND keep.end_at = t.end_at
+WHERE a.business_trip_id = t.id AND t.id <> keep.id;
+
+DELETE FROM business_trips t
+USING business_trips keep
+WHERE keep.destination = t.destination
+  AND keep.start_at = t.start_at
+  AND keep.end_at = t.end_at
+  AND keep.id < t.id;
+
+-- Keep the most recently imported amount of each employee on each trip
+DELETE FROM assignment_to_trips a
+USING assignment_to_trips newer
+WHERE newer.employee_id = a.employee_id
+  AND newer.business_trip_id = a.business_trip_id
+  AND newer.id > a.id;
+
+CREATE UNIQUE INDEX idx_employees_name ON employees (name);
+CREATE UNIQUE INDEX idx_business_trips_identity ON business_trips (destination, start_at, end_at);
+CREATE UNIQUE INDEX idx_assignment_to_trips_employee_trip ON assignment_to_trips (employee_id, business_trip_id);
+
+-- Aggregates may have changed, so cached pages are stale
+UPDATE data_versions SET version = version + 1, updated_at = now();
@@ -0,0 +1,13 @@
+-- One row per loader run; the app reads the totals for /metrics.
+CREATE TABLE loader_runs (
+    id          bigserial PRIMARY KEY,
+    file        text NOT NULL,
+    started_at  timestamptz NOT NULL,
+    finished_at timestamptz NOT NULL,
+    status      text NOT NULL,
+    processed   bigint NOT NULL,
+    overwritten bigint NOT NULL,
+    skipped     bigint NOT NULL,
+    failed      bigint NOT NULL,
+    error       text NOT NULL
+);
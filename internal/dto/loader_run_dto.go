@@ -0,0 +1,11 @@
+package dto
+
+// LoaderRunTotalsDTO sums the loader runs that ended with the same status
+type LoaderRunTotalsDTO struct {
+	Status      string
+	Runs        uint64
+	Processed   uint64
+	Overwritten uint64
+	Skipped     uint64
+	Failed      uint64
+}
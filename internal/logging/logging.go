@@ -0,0 +1,17 @@
+package logging
+
+import (
+	"io"
+	"log/slog"
+
+	"TP_Andreev/internal/config"
+)
+
+// New builds the application logger from cfg and writes to w
+func New(cfg config.LogConfig, w io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+	if cfg.Format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
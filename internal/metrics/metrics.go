@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are latency buckets in seconds suitable for HTTP requests
+// and database queries.
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Counter is a monotonically increasing counter safe for concurrent use.
+type Counter struct {
+	v atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+func (c *Counter) Add(n uint64) {
+	c.v.Add(n)
+}
+
+func (c *Counter) Value() uint64 {
+	return c.v.Load()
+}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	buckets []float64
+	counts  []atomic.Uint64 // one per bucket plus +Inf
+	sum     atomic.Uint64   // float64 bits
+	count   atomic.Uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]atomic.Uint64, len(buckets)+1)}
+}
+
+// Observe records a single value, e.g. a duration in seconds.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.counts[i].Add(1)
+	for {
+		old := h.sum.Load()
+		if h.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			break
+		}
+	}
+	h.count.Add(1)
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	return h.count.Load()
+}
+
+// vec keeps one child metric per combination of label values.
+type vec[T any] struct {
+	labels   []string
+	newChild func() *T
+	mu       sync.RWMutex
+	children map[string]*T
+	values   map[string][]string
+}
+
+func newVec[T any](labels []string, newChild func() *T) *vec[T] {
+	return &vec[T]{labels: labels, newChild: newChild, children: map[string]*T{}, values: map[string][]string{}}
+}
+
+func (v *vec[T]) with(values ...string) *T {
+	if len(values) != len(v.labels) {
+		panic("metrics: wrong number of label values")
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.RLock()
+	child, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return child
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if child, ok := v.children[key]; ok {
+		return child
+	}
+	child = v.newChild()
+	v.children[key] = child
+	v.values[key] = append([]string(nil), values...)
+	return child
+}
+
+// each calls fn for every child in a stable order.
+func (v *vec[T]) each(fn func(values []string, child *T)) {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	v.mu.RUnlock()
+	sort.Strings(keys)
+	for _, key := range keys {
+		v.mu.RLock()
+		child, values := v.children[key], v.values[key]
+		v.mu.RUnlock()
+		fn(values, child)
+	}
+}
+
+// CounterVec is a set of counters partitioned by label values.
+type CounterVec struct {
+	*vec[Counter]
+}
+
+// With returns the counter for the given label values, creating it if needed.
+func (c *CounterVec) With(values ...string) *Counter {
+	return c.with(values...)
+}
+
+// HistogramVec is a set of histograms partitioned by label values.
+type HistogramVec struct {
+	*vec[Histogram]
+}
+
+// With returns the histogram for the given label values, creating it if needed.
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.with(values...)
+}
+
+// HTTPPanics counts panics recovered by the HTTP recovery middleware.
+var HTTPPanics = Default.Counter("http_panics_total", "Panics recovered by the HTTP recovery middleware.")
+
+// HTTPRequests counts served requests by method, route pattern and status code.
+var HTTPRequests = Default.CounterVec("http_requests_total", "HTTP requests by method, route pattern and status code.", "method", "route", "status")
+
+// HTTPRequestDuration observes request latency by method and route pattern.
+var HTTPRequestDuration = Default.HistogramVec("http_request_duration_seconds", "HTTP request latency by method and route pattern.", DefaultBuckets, "method", "route")
+
+// DBQueries counts gorm queries by operation, table and outcome.
+var DBQueries = Default.CounterVec("db_queries_total", "Database queries by operation, table and outcome.", "operation", "table", "status")
+
+// DBQueryDuration observes gorm query latency by operation and table.
+var DBQueryDuration = Default.HistogramVec("db_query_duration_seconds", "Database query latency by operation and table.", DefaultBuckets, "operation", "table")
@@ -0,0 +1,66 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"TP_Andreev/internal/metrics"
+)
+
+func TestWriteTo(t *testing.T) {
+	reg := &metrics.Registry{}
+	reg.Counter("jobs_total", "Jobs done.").Add(3)
+	requests := reg.CounterVec("requests_total", "Requests.", "route", "status")
+	requests.With("/employee/:id", "200").Inc()
+	requests.With("/a\"b", "500").Add(2)
+	latency := reg.HistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	latency.With("/").Observe(0.05)
+	latency.With("/").Observe(0.5)
+	latency.With("/").Observe(3)
+	reg.GaugeFunc("pool_open", "Open connections.", func() float64 { return 4 })
+	reg.CounterVecFunc("runs_total", "Runs.", func() []metrics.Sample {
+		return []metrics.Sample{{Values: []string{"error"}, Value: 1}, {Values: []string{"ok"}, Value: 5}}
+	}, "status")
+
+	var b strings.Builder
+	if _, err := reg.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP jobs_total Jobs done.
+# TYPE jobs_total counter
+jobs_total 3
+# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{route="/a\"b",status="500"} 2
+requests_total{route="/employee/:id",status="200"} 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/",le="0.1"} 1
+latency_seconds_bucket{route="/",le="1"} 2
+latency_seconds_bucket{route="/",le="+Inf"} 3
+latency_seconds_sum{route="/"} 3.55
+latency_seconds_count{route="/"} 3
+# HELP pool_open Open connections.
+# TYPE pool_open gauge
+pool_open 4
+# HELP runs_total Runs.
+# TYPE runs_total counter
+runs_total{status="error"} 1
+runs_total{status="ok"} 5
+`
+	if b.String() != expected {
+		t.Errorf("Result was incorrect, got:\n%s\nwant:\n%s", b.String(), expected)
+	}
+}
+
+func TestDuplicateMetricPanics(t *testing.T) {
+	reg := &metrics.Registry{}
+	reg.Counter("x_total", "X.")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic on duplicate metric name")
+		}
+	}()
+	reg.Counter("x_total", "X again.")
+}
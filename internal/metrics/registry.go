@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default is the registry exposed on /metrics.
+var Default = &Registry{}
+
+// Registry holds metric families and renders them in the Prometheus text
+// exposition format (version 0.0.4).
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	names    map[string]bool
+}
+
+type family struct {
+	name, help, typ string
+	write           func(w *bufio.Writer, name string)
+}
+
+func (r *Registry) register(name, help, typ string, write func(w *bufio.Writer, name string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names == nil {
+		r.names = map[string]bool{}
+	}
+	if r.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.families = append(r.families, &family{name: name, help: help, typ: typ, write: write})
+}
+
+// Counter registers a counter without labels.
+func (r *Registry) Counter(name, help string) *Counter {
+	c := &Counter{}
+	r.register(name, help, "counter", func(w *bufio.Writer, name string) {
+		writeSample(w, name, nil, nil, float64(c.Value()))
+	})
+	return c
+}
+
+// CounterVec registers a counter partitioned by labels.
+func (r *Registry) CounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(labels, func() *Counter { return &Counter{} })}
+	r.register(name, help, "counter", func(w *bufio.Writer, name string) {
+		c.each(func(values []string, child *Counter) {
+			writeSample(w, name, labels, values, float64(child.Value()))
+		})
+	})
+	return c
+}
+
+// HistogramVec registers a histogram partitioned by labels. Buckets must be
+// sorted in increasing order.
+func (r *Registry) HistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{newVec(labels, func() *Histogram { return newHistogram(buckets) })}
+	bucketLabels := append(append([]string(nil), labels...), "le")
+	r.register(name, help, "histogram", func(w *bufio.Writer, name string) {
+		h.each(func(values []string, child *Histogram) {
+			var cumulative uint64
+			for i := range child.counts {
+				cumulative += child.counts[i].Load()
+				le := math.Inf(1)
+				if i < len(buckets) {
+					le = buckets[i]
+				}
+				writeSample(w, name+"_bucket", bucketLabels, append(values[:len(values):len(values)], formatFloat(le)), float64(cumulative))
+			}
+			writeSample(w, name+"_sum", labels, values, math.Float64frombits(child.sum.Load()))
+			writeSample(w, name+"_count", labels, values, float64(child.count.Load()))
+		})
+	})
+	return h
+}
+
+// GaugeFunc registers a gauge whose value is read from fn on every scrape.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	r.register(name, help, "gauge", func(w *bufio.Writer, name string) {
+		writeSample(w, name, nil, nil, fn())
+	})
+}
+
+// CounterFunc registers a counter whose value is read from fn on every scrape.
+func (r *Registry) CounterFunc(name, help string, fn func() float64) {
+	r.register(name, help, "counter", func(w *bufio.Writer, name string) {
+		writeSample(w, name, nil, nil, fn())
+	})
+}
+
+// Sample is a value with its label values, as returned to CounterVecFunc.
+type Sample struct {
+	Values []string
+	Value  float64
+}
+
+// CounterVecFunc registers a counter partitioned by labels whose samples
+// are read from fn on every scrape, e.g. totals kept in the database.
+func (r *Registry) CounterVecFunc(name, help string, fn func() []Sample, labels ...string) {
+	r.register(name, help, "counter", func(w *bufio.Writer, name string) {
+		for _, s := range fn() {
+			if len(s.Values) != len(labels) {
+				panic("metrics: wrong number of label values")
+			}
+			writeSample(w, name, labels, s.Values, s.Value)
+		}
+	})
+}
+
+// WriteTo writes all registered metrics in text exposition format.
+func (r *Registry) WriteTo(out io.Writer) (int64, error) {
+	r.mu.Lock()
+	families := append([]*family(nil), r.families...)
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: out}
+	w := bufio.NewWriter(cw)
+	for _, f := range families {
+		fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+		f.write(w, f.name)
+	}
+	err := w.Flush()
+	return cw.n, err
+}
+
+// Handler serves the registry for Prometheus scrapes.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(label)
+			w.WriteString(`="`)
+			w.WriteString(labelEscaper.Replace(values[i]))
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
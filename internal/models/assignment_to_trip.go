@@ -3,8 +3,8 @@ package models
 type AssignmentToTrip struct {
 	ID             uint `gorm:"primaryKey"`
 	MoneySpent     int `gorm:"not null"`
-	EmployeeID     uint
-	BusinessTripID uint
+	EmployeeID     uint `gorm:"uniqueIndex:idx_assignment_to_trips_employee_trip"`
+	BusinessTripID uint `gorm:"uniqueIndex:idx_assignment_to_trips_employee_trip"`
 	Employee     Employee     `gorm:"foreignKey:EmployeeID;references:ID"`
 	BusinessTrip BusinessTrip `gorm:"foreignKey:BusinessTripID;references:ID"`
 }
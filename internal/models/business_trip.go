@@ -4,9 +4,9 @@ import "time"
 
 type BusinessTrip struct {
 	ID          uint               `gorm:"primaryKey"`
-	Destination string             `gorm:"type:text;not null"`
-	StartAt     time.Time          `gorm:"type:date;not null"`
-	EndAt       time.Time          `gorm:"type:date;not null"`
+	Destination string             `gorm:"type:text;not null;uniqueIndex:idx_business_trips_identity"`
+	StartAt     time.Time          `gorm:"type:date;not null;uniqueIndex:idx_business_trips_identity"`
+	EndAt       time.Time          `gorm:"type:date;not null;uniqueIndex:idx_business_trips_identity"`
 	Assignments []AssignmentToTrip `gorm:"foreignKey:BusinessTripID"`
 	Employees   []Employee         `gorm:"many2many:assignment_to_trips;joinForeignKey:BusinessTripID;References:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 }
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// DataVersion is a counter bumped every time the named data set changes.
+// HTTP caching derives ETag and Last-Modified from it.
+type DataVersion struct {
+	Name      string    `gorm:"primaryKey;type:text"`
+	Version   uint64    `gorm:"not null;default:0"`
+	UpdatedAt time.Time `gorm:"not null"`
+}
@@ -2,7 +2,7 @@ package models
 
 type Employee struct {
 	ID            uint               `gorm:"primaryKey"`
-	Name          string             `gorm:"type:text;not null"`
+	Name          string             `gorm:"type:text;not null;uniqueIndex:idx_employees_name"`
 	Assignments   []AssignmentToTrip `gorm:"foreignKey:EmployeeID"`
 	BusinessTrips []BusinessTrip     `gorm:"many2many:assignment_to_trips;joinForeignKey:EmployeeID;References:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 }
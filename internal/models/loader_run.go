@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// LoaderRun records one run of the data loader. The loader exits before
+// Prometheus could scrape it, so the app exposes these rows on /metrics.
+type LoaderRun struct {
+	ID          uint      `gorm:"primaryKey"`
+	File        string    `gorm:"type:text;not null"`
+	StartedAt   time.Time `gorm:"not null"`
+	FinishedAt  time.Time `gorm:"not null"`
+	Status      string    `gorm:"type:text;not null"`
+	Processed   int       `gorm:"not null"`
+	Overwritten int       `gorm:"not null"`
+	Skipped     int       `gorm:"not null"`
+	Failed      int       `gorm:"not null"`
+	Error       string    `gorm:"type:text;not null"`
+}
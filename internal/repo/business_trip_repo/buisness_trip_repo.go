@@ -1,6 +1,8 @@
 package business_trip_repo
 
 import (
+	"context"
+
 	"TP_Andreev/internal/dto"
 	"TP_Andreev/internal/models"
 
@@ -15,9 +17,9 @@ func New(db *gorm.DB) *BusinessTripRepo {
 	return &BusinessTripRepo{db: db}
 }
 
-func (repo *BusinessTripRepo) All() (*[]dto.BuisnessTripDTO, error) {
+func (repo *BusinessTripRepo) All(ctx context.Context) (*[]dto.BuisnessTripDTO, error) {
 	var businessTrips []models.BusinessTrip
-	err := repo.db.Model(&models.BusinessTrip{}).Preload("Assignments").Preload("Assignments.Employee").Find(&businessTrips).Error
+	err := repo.db.WithContext(ctx).Model(&models.BusinessTrip{}).Preload("Assignments").Preload("Assignments.Employee").Find(&businessTrips).Error
 
 	var result []dto.BuisnessTripDTO
 
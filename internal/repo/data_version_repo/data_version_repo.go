@@ -0,0 +1,34 @@
+package data_version_repo
+
+import (
+	"context"
+	"time"
+
+	"TP_Andreev/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type DataVersionRepo struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) *DataVersionRepo {
+	return &DataVersionRepo{db: db}
+}
+
+// Current returns the sum of all data version counters and the time of the
+// latest change. Both stay zero until the first import.
+func (repo *DataVersionRepo) Current(ctx context.Context) (uint64, time.Time, error) {
+	var row struct {
+		Version   uint64
+		UpdatedAt *time.Time
+	}
+	err := repo.db.WithContext(ctx).Model(&models.DataVersion{}).
+		Select("COALESCE(SUM(version), 0) AS version, MAX(updated_at) AS updated_at").
+		Scan(&row).Error
+	if err != nil || row.UpdatedAt == nil {
+		return row.Version, time.Time{}, err
+	}
+	return row.Version, *row.UpdatedAt, nil
+}
@@ -1,6 +1,8 @@
 package employee_repo
 
 import (
+	"context"
+
 	"TP_Andreev/internal/dto"
 	"TP_Andreev/internal/models"
 
@@ -15,9 +17,9 @@ func New(db *gorm.DB) *EmployeeRepo {
 	return &EmployeeRepo{db: db}
 }
 
-func (repo *EmployeeRepo) Find(id uint) (*dto.EmployeeDTO, error) {
+func (repo *EmployeeRepo) Find(ctx context.Context, id uint) (*dto.EmployeeDTO, error) {
 	var employee models.Employee
-	err := repo.db.Model(&models.Employee{}).Preload("Assignments").Preload("Assignments.BusinessTrip").Find(&employee, id).Error
+	err := repo.db.WithContext(ctx).Model(&models.Employee{}).Preload("Assignments").Preload("Assignments.BusinessTrip").Find(&employee, id).Error
 
 	employeeDTO := dto.EmployeeDTO{
 		ID:   employee.ID,
@@ -45,9 +47,9 @@ func (repo *EmployeeRepo) Find(id uint) (*dto.EmployeeDTO, error) {
 	return &employeeDTO, err
 }
 
-func (repo *EmployeeRepo) All() (*[]dto.EmployeeDTO, error) {
+func (repo *EmployeeRepo) All(ctx context.Context) (*[]dto.EmployeeDTO, error) {
 	var employees []models.Employee
-	err := repo.db.Model(&models.Employee{}).Preload("Assignments").Preload("Assignments.BusinessTrip").Find(&employees).Error
+	err := repo.db.WithContext(ctx).Model(&models.Employee{}).Preload("Assignments").Preload("Assignments.BusinessTrip").Find(&employees).Error
 
 	var result []dto.EmployeeDTO
 
@@ -0,0 +1,28 @@
+package loader_run_repo
+
+import (
+	"context"
+
+	"TP_Andreev/internal/dto"
+	"TP_Andreev/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type LoaderRunRepo struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) *LoaderRunRepo {
+	return &LoaderRunRepo{db: db}
+}
+
+// Totals returns the number of runs and their row counts by status
+func (repo *LoaderRunRepo) Totals(ctx context.Context) ([]dto.LoaderRunTotalsDTO, error) {
+	var totals []dto.LoaderRunTotalsDTO
+	err := repo.db.WithContext(ctx).Model(&models.LoaderRun{}).
+		Select("status, COUNT(*) AS runs, SUM(processed)::bigint AS processed, SUM(overwritten)::bigint AS overwritten, SUM(skipped)::bigint AS skipped, SUM(failed)::bigint AS failed").
+		Group("status").Order("status").
+		Scan(&totals).Error
+	return totals, err
+}
@@ -1,12 +1,25 @@
 package repository
 
-import "TP_Andreev/internal/dto"
+import (
+	"context"
+	"time"
+
+	"TP_Andreev/internal/dto"
+)
 
 type EmployeeRepo interface {
-	Find(id uint) (*dto.EmployeeDTO, error)
-	All() (*[]dto.EmployeeDTO, error)
+	Find(ctx context.Context, id uint) (*dto.EmployeeDTO, error)
+	All(ctx context.Context) (*[]dto.EmployeeDTO, error)
 }
 
 type BusinessTripRepo interface {
-	All() (*[]dto.BuisnessTripDTO, error)
+	All(ctx context.Context) (*[]dto.BuisnessTripDTO, error)
+}
+
+type DataVersionRepo interface {
+	Current(ctx context.Context) (uint64, time.Time, error)
+}
+
+type LoaderRunRepo interface {
+	Totals(ctx context.Context) ([]dto.LoaderRunTotalsDTO, error)
 }
@@ -2,59 +2,145 @@ package service
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"strings"
 	"time"
 
 	"TP_Andreev/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type DataLoaderService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	logger *slog.Logger
 }
 
-func NewDataLoaderService(db *gorm.DB) *DataLoaderService {
-	return &DataLoaderService{db: db}
+// LoadStats summarizes a single LoadEmployeeTravelData run
+type LoadStats struct {
+	Processed   int // rows inserted or updated
+	Overwritten int // rows whose employee and trip repeat an earlier row of the file, replacing its amount
+	Skipped     int // incomplete rows
+	Failed      int // rows that could not be parsed or stored
 }
 
-func (ds *DataLoaderService) LoadEmployeeTravelData(filePath string) error {
+// errIncompleteRow marks rows that are skipped rather than failed
+var errIncompleteRow = errors.New("incomplete row")
+
+func NewDataLoaderService(db *gorm.DB, logger *slog.Logger) *DataLoaderService {
+	return &DataLoaderService{db: db, logger: logger}
+}
+
+// LoadEmployeeTravelData imports the CSV file and reports row counts both
+// in the returned stats and in a loader_runs row, which the app exposes on
+// /metrics (see RegisterLoaderMetrics).
+func (ds *DataLoaderService) LoadEmployeeTravelData(filePath string) (LoadStats, error) {
+	started := time.Now().UTC()
+	stats, err := ds.load(filePath)
+
+	run := models.LoaderRun{
+		File:        filePath,
+		StartedAt:   started,
+		FinishedAt:  time.Now().UTC(),
+		Status:      "ok",
+		Processed:   stats.Processed,
+		Overwritten: stats.Overwritten,
+		Skipped:     stats.Skipped,
+		Failed:      stats.Failed,
+	}
+	if err != nil {
+		run.Status = "error"
+		run.Error = err.Error()
+	}
+	if rerr := ds.db.Create(&run).Error; rerr != nil {
+		ds.logger.Error("failed to record loader run", "error", rerr)
+	}
+	return stats, err
+}
+
+func (ds *DataLoaderService) load(filePath string) (LoadStats, error) {
+	var stats LoadStats
+
 	file, err := os.Open(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return stats, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
 	reader := csv.NewReader(file)
 	records, err := reader.ReadAll()
 	if err != nil {
-		return fmt.Errorf("failed to read CSV: %w", err)
+		return stats, fmt.Errorf("failed to read CSV: %w", err)
 	}
 
 	if len(records) < 2 {
-		return fmt.Errorf("CSV file is empty or has no data rows")
+		return stats, fmt.Errorf("CSV file is empty or has no data rows")
 	}
 
+	// Rows by the assignment they stored, to report repeats within the file
+	seen := map[[2]uint]int{}
+
 	// Skip header row (index 0)
 	for i := 1; i < len(records); i++ {
 		record := records[i]
 		if len(record) < 7 {
-			fmt.Printf("Skipping row %d: insufficient columns\n", i)
+			ds.logger.Warn("skipping row: insufficient columns", "row", i, "columns", len(record))
+			stats.Skipped++
 			continue
 		}
 
-		err := ds.processRow(record)
+		assignment, err := ds.processRow(record)
+		if errors.Is(err, errIncompleteRow) {
+			stats.Skipped++
+			continue
+		}
 		if err != nil {
-			fmt.Printf("Error processing row %d: %v\n", i, err)
+			ds.logger.Warn("failed to process row", "row", i, "error", err)
+			stats.Failed++
+			continue
+		}
+		key := [2]uint{assignment.EmployeeID, assignment.BusinessTripID}
+		if first, ok := seen[key]; ok {
+			ds.logger.Warn("row repeats employee and trip of an earlier row, its amount replaces the earlier one", "row", i, "earlier_row", first)
+			stats.Overwritten++
 			continue
 		}
+		seen[key] = i
+		stats.Processed++
 	}
 
-	return nil
+	if stats.Processed+stats.Overwritten > 0 {
+		// Invalidate cached pages: their ETags are derived from these counters
+		if err := ds.bumpDataVersions("employees", "business_trips", "assignment_to_trips"); err != nil {
+			return stats, fmt.Errorf("failed to bump data versions: %w", err)
+		}
+	}
+
+	return stats, nil
 }
 
-func (ds *DataLoaderService) processRow(record []string) error {
+// bumpDataVersions increments the version counters of the named data sets
+func (ds *DataLoaderService) bumpDataVersions(names ...string) error {
+	now := time.Now().UTC()
+	versions := make([]models.DataVersion, len(names))
+	for i, name := range names {
+		versions[i] = models.DataVersion{Name: name, Version: 1, UpdatedAt: now}
+	}
+	return ds.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "name"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"version":    gorm.Expr("data_versions.version + 1"),
+			"updated_at": now,
+		}),
+	}).Create(&versions).Error
+}
+
+// processRow upserts the employee, trip and assignment of a CSV row and
+// returns the stored assignment
+func (ds *DataLoaderService) processRow(record []string) (models.AssignmentToTrip, error) {
 	// CSV columns: Department, Employee, Travel Start Date, Travel End Date, Destination(s), Purpose Of Travel, Actual Total Expenses
 	employeeName := strings.TrimSpace(record[1])
 	destination := strings.TrimSpace(record[4])
@@ -63,18 +149,18 @@ func (ds *DataLoaderService) processRow(record []string) error {
 	moneySpentStr := strings.TrimSpace(record[6])
 
 	if employeeName == "" || destination == "" {
-		return nil // Skip incomplete records
+		return models.AssignmentToTrip{}, errIncompleteRow // Skip incomplete records
 	}
 
 	// Parse dates
 	startDate, err := time.Parse("2006/01/02", startDateStr)
 	if err != nil {
-		return fmt.Errorf("invalid start date format: %s", startDateStr)
+		return models.AssignmentToTrip{}, fmt.Errorf("invalid start date format: %s", startDateStr)
 	}
 
 	endDate, err := time.Parse("2006/01/02", endDateStr)
 	if err != nil {
-		return fmt.Errorf("invalid end date format: %s", endDateStr)
+		return models.AssignmentToTrip{}, fmt.Errorf("invalid end date format: %s", endDateStr)
 	}
 
 	// Parse money spent
@@ -88,38 +174,46 @@ func (ds *DataLoaderService) processRow(record []string) error {
 		}
 	}
 
-	// Find or create employee
-	var employee models.Employee
-	result := ds.db.Where("name = ?", employeeName).FirstOrCreate(&employee, models.Employee{Name: employeeName})
-	if result.Error != nil {
-		return fmt.Errorf("failed to create/find employee: %w", result.Error)
-	}
-
-	// Find or create business trip
-	var trip models.BusinessTrip
-	result = ds.db.Where(
-		"destination = ? AND start_at = ? AND end_at = ?",
-		destination, startDate, endDate,
-	).FirstOrCreate(&trip, models.BusinessTrip{
-		Destination: destination,
-		StartAt:     startDate,
-		EndAt:       endDate,
-	})
-	if result.Error != nil {
-		return fmt.Errorf("failed to create/find business trip: %w", result.Error)
-	}
-
-	// Create assignment
-	assignment := models.AssignmentToTrip{
-		EmployeeID:     employee.ID,
-		BusinessTripID: trip.ID,
-		MoneySpent:     moneySpent,
-	}
+	// Upsert on the natural keys, so re-importing a file updates amounts
+	// instead of duplicating them
+	var assignment models.AssignmentToTrip
+	err = ds.db.Transaction(func(tx *gorm.DB) error {
+		// A no-op update makes RETURNING yield the id of an existing row
+		employee := models.Employee{Name: employeeName}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name"}),
+		}).Create(&employee).Error
+		if err != nil {
+			return fmt.Errorf("failed to upsert employee: %w", err)
+		}
 
-	result = ds.db.Create(&assignment)
-	if result.Error != nil {
-		return fmt.Errorf("failed to create assignment: %w", result.Error)
-	}
+		trip := models.BusinessTrip{
+			Destination: destination,
+			StartAt:     startDate,
+			EndAt:       endDate,
+		}
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "destination"}, {Name: "start_at"}, {Name: "end_at"}},
+			DoUpdates: clause.AssignmentColumns([]string{"destination"}),
+		}).Create(&trip).Error
+		if err != nil {
+			return fmt.Errorf("failed to upsert business trip: %w", err)
+		}
 
-	return nil
+		assignment = models.AssignmentToTrip{
+			EmployeeID:     employee.ID,
+			BusinessTripID: trip.ID,
+			MoneySpent:     moneySpent,
+		}
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "employee_id"}, {Name: "business_trip_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"money_spent"}),
+		}).Create(&assignment).Error
+		if err != nil {
+			return fmt.Errorf("failed to upsert assignment: %w", err)
+		}
+		return nil
+	})
+	return assignment, err
 }
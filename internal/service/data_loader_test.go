@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"TP_Andreev/internal/db/migrations"
+	"TP_Andreev/internal/metrics"
+	"TP_Andreev/internal/models"
+	"TP_Andreev/internal/repo/loader_run_repo"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// testDB connects to the disposable database in TEST_DATABASE_DSN, migrated
+// to the latest version and emptied; the test is skipped without it.
+func testDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if _, err := migrations.Up(context.Background(), db, slog.New(slog.DiscardHandler)); err != nil {
+		t.Fatal(err)
+	}
+	truncate(t, db)
+	return db
+}
+
+func truncate(t *testing.T, db *gorm.DB) {
+	if err := db.Exec("TRUNCATE assignment_to_trips, business_trips, employees, loader_runs RESTART IDENTITY CASCADE").Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessRowUpserts(t *testing.T) {
+	db := testDB(t)
+	ds := NewDataLoaderService(db, slog.New(slog.DiscardHandler))
+
+	record := []string{"IT", "Ann Smith", "2024/01/02", "2024/01/05", "Paris", "Conference", "10.00"}
+	first, err := ds.processRow(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record[6] = "12.50"
+	second, err := ds.processRow(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != second.ID || first.EmployeeID != second.EmployeeID || first.BusinessTripID != second.BusinessTripID {
+		t.Errorf("re-import created new rows: %+v then %+v", first, second)
+	}
+
+	var assignments []models.AssignmentToTrip
+	if err := db.Find(&assignments).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(assignments) != 1 || assignments[0].MoneySpent != 1250 {
+		t.Errorf("Expected a single assignment of 1250 cents, got %+v", assignments)
+	}
+}
+
+func TestLoadCountsOverwrittenRows(t *testing.T) {
+	db := testDB(t)
+	ds := NewDataLoaderService(db, slog.New(slog.DiscardHandler))
+
+	file := filepath.Join(t.TempDir(), "trips.csv")
+	csv := "Department,Employee,Start,End,Destination,Purpose,Expenses\n" +
+		"IT,Ann Smith,2024/01/02,2024/01/05,Paris,Conference,10.00\n" +
+		"IT,Bob Stone,2024/01/02,2024/01/05,Paris,Conference,20.00\n" +
+		"IT,Ann Smith,2024/01/02,2024/01/05,Paris,Conference,12.50\n"
+	if err := os.WriteFile(file, []byte(csv), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := ds.LoadEmployeeTravelData(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (LoadStats{Processed: 2, Overwritten: 1}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// Loading the same file again must not add rows
+	if _, err := ds.LoadEmployeeTravelData(file); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	if err := db.Model(&models.AssignmentToTrip{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 assignments after re-import, got %d", count)
+	}
+}
+
+func TestLoaderRunsReachMetrics(t *testing.T) {
+	db := testDB(t)
+	ds := NewDataLoaderService(db, slog.New(slog.DiscardHandler))
+
+	file := filepath.Join(t.TempDir(), "trips.csv")
+	csv := "Department,Employee,Start,End,Destination,Purpose,Expenses\n" +
+		"IT,Ann Smith,2024/01/02,2024/01/05,Paris,Conference,10.00\n" +
+		"IT,Bob Stone,2024/01/02,2024/01/05,Paris,Conference,20.00\n" +
+		"IT,,2024/01/02,2024/01/05,Paris,Conference,30.00\n" +
+		"IT,Cid Moss,tomorrow,2024/01/05,Paris,Conference,40.00\n"
+	if err := os.WriteFile(file, []byte(csv), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.LoadEmployeeTravelData(file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.LoadEmployeeTravelData(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Fatal("Expected an error for a missing file")
+	}
+
+	// The app reads the runs back on every scrape
+	reg := &metrics.Registry{}
+	RegisterLoaderMetrics(reg, loader_run_repo.New(db), time.Second, slog.New(slog.DiscardHandler))
+	var b strings.Builder
+	if _, err := reg.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`loader_runs_total{status="error"} 1`,
+		`loader_runs_total{status="ok"} 1`,
+		`loader_rows_total{result="processed"} 2`,
+		`loader_rows_total{result="skipped"} 1`,
+		`loader_rows_total{result="failed"} 1`,
+	} {
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Errorf("exposition misses %s:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestUniqueKeysMigrationMergesDuplicates(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	logger := slog.New(slog.DiscardHandler)
+	all, err := migrations.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Roll back to the schema without unique indexes and recreate the
+	// duplicates earlier loader runs left behind
+	if _, err := migrations.Down(ctx, db, logger, len(all)-1); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { migrations.Up(ctx, db, logger) })
+	truncate(t, db)
+	for _, stmt := range []string{
+		"INSERT INTO employees (name) VALUES ('Ann Smith'), ('Ann Smith')",
+		"INSERT INTO business_trips (destination, start_at, end_at) VALUES ('Paris', '2024-01-02', '2024-01-05'), ('Paris', '2024-01-02', '2024-01-05')",
+		"INSERT INTO assignment_to_trips (money_spent, employee_id, business_trip_id) VALUES (1000, 1, 1), (1250, 2, 2)",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := migrations.Up(ctx, db, logger); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrations.Check(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+
+	var employees, trips int64
+	db.Model(&models.Employee{}).Count(&employees)
+	db.Model(&models.BusinessTrip{}).Count(&trips)
+	var assignments []models.AssignmentToTrip
+	if err := db.Find(&assignments).Error; err != nil {
+		t.Fatal(err)
+	}
+	if employees != 1 || trips != 1 {
+		t.Errorf("Expected duplicates merged, got %d employees and %d trips", employees, trips)
+	}
+	if len(assignments) != 1 || assignments[0].MoneySpent != 1250 || assignments[0].EmployeeID != 1 || assignments[0].BusinessTripID != 1 {
+		t.Errorf("Expected the newest amount on the surviving rows, got %+v", assignments)
+	}
+}
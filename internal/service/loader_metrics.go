@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"TP_Andreev/internal/dto"
+	"TP_Andreev/internal/metrics"
+	repository "TP_Andreev/internal/repo"
+)
+
+// RegisterLoaderMetrics exposes the loader runs recorded in the database
+// as loader_runs_total{status} and loader_rows_total{result}. Each scrape
+// reads the totals with the given timeout; on error the series are omitted.
+func RegisterLoaderMetrics(reg *metrics.Registry, repo repository.LoaderRunRepo, timeout time.Duration, logger *slog.Logger) {
+	totals := func() []dto.LoaderRunTotalsDTO {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		totals, err := repo.Totals(ctx)
+		if err != nil {
+			logger.Error("failed to read loader runs", "error", err)
+			return nil
+		}
+		return totals
+	}
+
+	reg.CounterVecFunc("loader_runs_total", "Data loader runs by outcome.", func() []metrics.Sample {
+		var samples []metrics.Sample
+		for _, t := range totals() {
+			samples = append(samples, metrics.Sample{Values: []string{t.Status}, Value: float64(t.Runs)})
+		}
+		return samples
+	}, "status")
+
+	// Rows of all runs: processed, overwritten (repeated in the same file),
+	// skipped (incomplete) or failed
+	reg.CounterVecFunc("loader_rows_total", "CSV rows handled by the data loader by result.", func() []metrics.Sample {
+		var rows [4]uint64
+		for _, t := range totals() {
+			rows[0] += t.Processed
+			rows[1] += t.Overwritten
+			rows[2] += t.Skipped
+			rows[3] += t.Failed
+		}
+		results := [4]string{"processed", "overwritten", "skipped", "failed"}
+		samples := make([]metrics.Sample, len(results))
+		for i, result := range results {
+			samples[i] = metrics.Sample{Values: []string{result}, Value: float64(rows[i])}
+		}
+		return samples
+	}, "result")
+}
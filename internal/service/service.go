@@ -2,6 +2,8 @@ package service
 
 import (
 	repository "TP_Andreev/internal/repo"
+	"context"
+	"log/slog"
 	"sort"
 	"time"
 )
@@ -9,6 +11,7 @@ import (
 type Service struct {
 	employeeRepo     repository.EmployeeRepo
 	businessTripRepo repository.BusinessTripRepo
+	logger           *slog.Logger
 }
 
 type EmployeeTripData struct {
@@ -33,12 +36,26 @@ type GraphData struct {
 	Y int `json:"y"`
 }
 
-func New(employeeRepo repository.EmployeeRepo, businessTripRepo repository.BusinessTripRepo) *Service {
-	return &Service{employeeRepo: employeeRepo, businessTripRepo: businessTripRepo}
+func New(employeeRepo repository.EmployeeRepo, businessTripRepo repository.BusinessTripRepo, logger *slog.Logger) *Service {
+	return &Service{employeeRepo: employeeRepo, businessTripRepo: businessTripRepo, logger: logger}
 }
 
-func (s *Service) GetAllEmployeeTrips() *[]EmployeeTripData {
-	data, _ := s.employeeRepo.All()
+// fail logs a repository error and returns it. Errors of cancelled or timed
+// out requests are expected and logged at debug level only.
+func (s *Service) fail(ctx context.Context, op string, err error) error {
+	level := slog.LevelError
+	if ctx.Err() != nil {
+		level = slog.LevelDebug
+	}
+	s.logger.Log(ctx, level, "repository call failed", "op", op, "error", err)
+	return err
+}
+
+func (s *Service) GetAllEmployeeTrips(ctx context.Context) (*[]EmployeeTripData, error) {
+	data, err := s.employeeRepo.All(ctx)
+	if err != nil {
+		return nil, s.fail(ctx, "employees.all", err)
+	}
 
 	res := []EmployeeTripData{}
 	for _, d := range *data {
@@ -69,21 +86,24 @@ func (s *Service) GetAllEmployeeTrips() *[]EmployeeTripData {
 		return t2.Before(t1)
 	})
 
-	return &res
+	return &res, nil
 }
 
-func (s *Service) GetMoneySpentByAllYears() *[]GraphData {
+func (s *Service) GetMoneySpentByAllYears(ctx context.Context) (*[]GraphData, error) {
 	strategy := &MoneySpentStrategy{}
-	return s.aggregateByYearsWithStrategy(strategy)
+	return s.aggregateByYearsWithStrategy(ctx, strategy)
 }
 
-func (s *Service) GetTripCountByAllYears() *[]GraphData {
+func (s *Service) GetTripCountByAllYears(ctx context.Context) (*[]GraphData, error) {
 	strategy := &TripCountStrategy{}
-	return s.aggregateTripsWithStrategy(strategy)
+	return s.aggregateTripsWithStrategy(ctx, strategy)
 }
 
-func (s *Service) GetEmployeeTripCountByAllYears(id int) *[]GraphData {
-	data, _ := s.employeeRepo.Find(uint(id))
+func (s *Service) GetEmployeeTripCountByAllYears(ctx context.Context, id int) (*[]GraphData, error) {
+	data, err := s.employeeRepo.Find(ctx, uint(id))
+	if err != nil {
+		return nil, s.fail(ctx, "employees.find", err)
+	}
 
 	aggregator := NewYearlyAggregator()
 	for _, t := range (*data).Trips {
@@ -91,11 +111,14 @@ func (s *Service) GetEmployeeTripCountByAllYears(id int) *[]GraphData {
 		aggregator.AddValue(year, 1)
 	}
 
-	return aggregator.GetResults()
+	return aggregator.GetResults(), nil
 }
 
-func (s *Service) GetEmployeeStat(id int) *EmployeeData {
-	data, _ := s.employeeRepo.Find(uint(id))
+func (s *Service) GetEmployeeStat(ctx context.Context, id int) (*EmployeeData, error) {
+	data, err := s.employeeRepo.Find(ctx, uint(id))
+	if err != nil {
+		return nil, s.fail(ctx, "employees.find", err)
+	}
 	name := data.Name
 
 	aggregator := NewYearlyStatAggregator()
@@ -110,11 +133,14 @@ func (s *Service) GetEmployeeStat(id int) *EmployeeData {
 		MoneySpent:    aggregator.GetTotalMoneySpent(),
 		AvgTripCount:  aggregator.GetAverageTripsPerYear(),
 		AvgMoneySpent: aggregator.GetAverageMoneyPerYear(),
-	}
+	}, nil
 }
 
-func (s *Service) aggregateByYearsWithStrategy(strategy AggregationStrategy) *[]GraphData {
-	data, _ := s.employeeRepo.All()
+func (s *Service) aggregateByYearsWithStrategy(ctx context.Context, strategy AggregationStrategy) (*[]GraphData, error) {
+	data, err := s.employeeRepo.All(ctx)
+	if err != nil {
+		return nil, s.fail(ctx, "employees.all", err)
+	}
 	aggregator := NewYearlyAggregator()
 
 	for _, employee := range *data {
@@ -125,11 +151,14 @@ func (s *Service) aggregateByYearsWithStrategy(strategy AggregationStrategy) *[]
 		}
 	}
 
-	return aggregator.GetResults()
+	return aggregator.GetResults(), nil
 }
 
-func (s *Service) aggregateTripsWithStrategy(strategy AggregationStrategy) *[]GraphData {
-	data, _ := s.businessTripRepo.All()
+func (s *Service) aggregateTripsWithStrategy(ctx context.Context, strategy AggregationStrategy) (*[]GraphData, error) {
+	data, err := s.businessTripRepo.All(ctx)
+	if err != nil {
+		return nil, s.fail(ctx, "business_trips.all", err)
+	}
 	aggregator := NewYearlyAggregator()
 
 	for _, trip := range *data {
@@ -138,5 +167,5 @@ func (s *Service) aggregateTripsWithStrategy(strategy AggregationStrategy) *[]Gr
 		aggregator.AddValue(year, value)
 	}
 
-	return aggregator.GetResults()
+	return aggregator.GetResults(), nil
 }
@@ -2,8 +2,13 @@ package service_test
 
 import (
 	"TP_Andreev/internal/dto"
+	"TP_Andreev/internal/metrics"
 	"TP_Andreev/internal/service"
+	"context"
+	"errors"
+	"log/slog"
 	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,13 +19,13 @@ type mockEmployeeRepo struct {
 	mock.Mock
 }
 
-func (m *mockEmployeeRepo) Find(id uint) (*dto.EmployeeDTO, error) {
-	args := m.Called(id)
+func (m *mockEmployeeRepo) Find(ctx context.Context, id uint) (*dto.EmployeeDTO, error) {
+	args := m.Called(ctx, id)
 	return args.Get(0).(*dto.EmployeeDTO), args.Error(1)
 }
 
-func (m *mockEmployeeRepo) All() (*[]dto.EmployeeDTO, error) {
-	args := m.Called()
+func (m *mockEmployeeRepo) All(ctx context.Context) (*[]dto.EmployeeDTO, error) {
+	args := m.Called(ctx)
 	return args.Get(0).(*[]dto.EmployeeDTO), args.Error(1)
 }
 
@@ -28,11 +33,20 @@ type mockBusinessTripRepo struct {
 	mock.Mock
 }
 
-func (m *mockBusinessTripRepo) All() (*[]dto.BuisnessTripDTO, error) {
-	args := m.Called()
+func (m *mockBusinessTripRepo) All(ctx context.Context) (*[]dto.BuisnessTripDTO, error) {
+	args := m.Called(ctx)
 	return args.Get(0).(*[]dto.BuisnessTripDTO), args.Error(1)
 }
 
+type mockLoaderRunRepo struct {
+	mock.Mock
+}
+
+func (m *mockLoaderRunRepo) Totals(ctx context.Context) ([]dto.LoaderRunTotalsDTO, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]dto.LoaderRunTotalsDTO), args.Error(1)
+}
+
 var employeeDtoArray *[]dto.EmployeeDTO = &[]dto.EmployeeDTO{
 	{
 		ID:   1,
@@ -123,7 +137,7 @@ func TestGetAllEmployeeTrips(t *testing.T) {
 	mockEmployeeRepo := new(mockEmployeeRepo)
 	mockBusinessTripRepo := new(mockBusinessTripRepo)
 
-	mockEmployeeRepo.On("All").Return(
+	mockEmployeeRepo.On("All", mock.Anything).Return(
 		employeeDtoArray,
 		nil,
 	)
@@ -135,9 +149,12 @@ func TestGetAllEmployeeTrips(t *testing.T) {
 		{Id: 1, Name: "A", Destination: "Dest1", Date: "01.01.2020", Duration: 11, MoneySpent: 10},
 	}
 
-	service := service.New(mockEmployeeRepo, mockBusinessTripRepo)
+	service := service.New(mockEmployeeRepo, mockBusinessTripRepo, slog.New(slog.DiscardHandler))
 
-	actual := service.GetAllEmployeeTrips()
+	actual, err := service.GetAllEmployeeTrips(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !slices.Equal(expected, *actual) {
 		t.Errorf("Result was incorrect, got: %v, want: %v.", *actual, expected)
@@ -148,7 +165,7 @@ func TestGetMoneySpentByAllYears(t *testing.T) {
 	mockEmployeeRepo := new(mockEmployeeRepo)
 	mockBusinessTripRepo := new(mockBusinessTripRepo)
 
-	mockEmployeeRepo.On("All").Return(
+	mockEmployeeRepo.On("All", mock.Anything).Return(
 		employeeDtoArray,
 		nil,
 	)
@@ -159,9 +176,12 @@ func TestGetMoneySpentByAllYears(t *testing.T) {
 		{X: 2022, Y: 15},
 	}
 
-	service := service.New(mockEmployeeRepo, mockBusinessTripRepo)
+	service := service.New(mockEmployeeRepo, mockBusinessTripRepo, slog.New(slog.DiscardHandler))
 
-	actual := service.GetMoneySpentByAllYears()
+	actual, err := service.GetMoneySpentByAllYears(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !slices.Equal(expected, *actual) {
 		t.Errorf("Result was incorrect, got: %v, want: %v.", *actual, expected)
@@ -172,7 +192,7 @@ func TestGetTripCountByAllYears(t *testing.T) {
 	mockEmployeeRepo := new(mockEmployeeRepo)
 	mockBusinessTripRepo := new(mockBusinessTripRepo)
 
-	mockBusinessTripRepo.On("All").Return(
+	mockBusinessTripRepo.On("All", mock.Anything).Return(
 		buisnessTripDtoArray,
 		nil,
 	)
@@ -183,9 +203,12 @@ func TestGetTripCountByAllYears(t *testing.T) {
 		{X: 2022, Y: 1},
 	}
 
-	service := service.New(mockEmployeeRepo, mockBusinessTripRepo)
+	service := service.New(mockEmployeeRepo, mockBusinessTripRepo, slog.New(slog.DiscardHandler))
 
-	actual := service.GetTripCountByAllYears()
+	actual, err := service.GetTripCountByAllYears(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !slices.Equal(expected, *actual) {
 		t.Errorf("Result was incorrect, got: %v, want: %v.", *actual, expected)
@@ -196,7 +219,7 @@ func TestGetEmployeeTripCountByAllYears(t *testing.T) {
 	mockEmployeeRepo := new(mockEmployeeRepo)
 	mockBusinessTripRepo := new(mockBusinessTripRepo)
 
-	mockEmployeeRepo.On("Find", uint(1)).Return(
+	mockEmployeeRepo.On("Find", mock.Anything, uint(1)).Return(
 		employeeDto,
 		nil,
 	)
@@ -207,9 +230,12 @@ func TestGetEmployeeTripCountByAllYears(t *testing.T) {
 		{X: 2022, Y: 1},
 	}
 
-	service := service.New(mockEmployeeRepo, mockBusinessTripRepo)
+	service := service.New(mockEmployeeRepo, mockBusinessTripRepo, slog.New(slog.DiscardHandler))
 
-	actual := service.GetEmployeeTripCountByAllYears(1)
+	actual, err := service.GetEmployeeTripCountByAllYears(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !slices.Equal(expected, *actual) {
 		t.Errorf("Result was incorrect, got: %v, want: %v.", *actual, expected)
@@ -220,7 +246,7 @@ func TestGetEmployeeStat(t *testing.T) {
 	mockEmployeeRepo := new(mockEmployeeRepo)
 	mockBusinessTripRepo := new(mockBusinessTripRepo)
 
-	mockEmployeeRepo.On("Find", uint(1)).Return(
+	mockEmployeeRepo.On("Find", mock.Anything, uint(1)).Return(
 		employeeDto,
 		nil,
 	)
@@ -233,11 +259,61 @@ func TestGetEmployeeStat(t *testing.T) {
 		AvgMoneySpent: 20,
 	}
 
-	service := service.New(mockEmployeeRepo, mockBusinessTripRepo)
+	service := service.New(mockEmployeeRepo, mockBusinessTripRepo, slog.New(slog.DiscardHandler))
 
-	actual := service.GetEmployeeStat(1)
+	actual, err := service.GetEmployeeStat(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if *actual != expected {
 		t.Errorf("Result was incorrect, got: %v, want: %v.", *actual, expected)
 	}
 }
+
+func TestRepoErrorIsReturned(t *testing.T) {
+	mockEmployeeRepo := new(mockEmployeeRepo)
+	mockBusinessTripRepo := new(mockBusinessTripRepo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mockEmployeeRepo.On("All", ctx).Return(
+		(*[]dto.EmployeeDTO)(nil),
+		ctx.Err(),
+	)
+
+	service := service.New(mockEmployeeRepo, mockBusinessTripRepo, slog.New(slog.DiscardHandler))
+
+	if _, err := service.GetAllEmployeeTrips(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v.", err)
+	}
+}
+
+func TestRegisterLoaderMetrics(t *testing.T) {
+	mockLoaderRunRepo := new(mockLoaderRunRepo)
+	mockLoaderRunRepo.On("Totals", mock.Anything).Return([]dto.LoaderRunTotalsDTO{
+		{Status: "error", Runs: 1, Processed: 3, Failed: 1},
+		{Status: "ok", Runs: 2, Processed: 10, Overwritten: 1, Skipped: 2},
+	}, nil)
+
+	reg := &metrics.Registry{}
+	service.RegisterLoaderMetrics(reg, mockLoaderRunRepo, time.Second, slog.New(slog.DiscardHandler))
+
+	var b strings.Builder
+	if _, err := reg.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`loader_runs_total{status="error"} 1`,
+		`loader_runs_total{status="ok"} 2`,
+		`loader_rows_total{result="processed"} 13`,
+		`loader_rows_total{result="overwritten"} 1`,
+		`loader_rows_total{result="skipped"} 2`,
+		`loader_rows_total{result="failed"} 1`,
+	} {
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Errorf("exposition misses %s:\n%s", want, b.String())
+		}
+	}
+}
@@ -7,11 +7,13 @@ import (
 	"strconv"
 
 	"TP_Andreev/internal/service"
+	"TP_Andreev/internal/transport/http/middleware"
 	"TP_Andreev/internal/transport/http/router"
 )
 
 type EmployeeController struct {
 	service service.Service
+	tmpl    *template.Template
 }
 
 type jsData struct {
@@ -24,24 +26,38 @@ type tmplData struct {
 	JS    jsData
 }
 
-var tmpl = template.Must(
-	template.Must(
-		template.New("jsData").Parse(src),
-	).ParseFiles("web/templates/employee.html"),
-)
-
-func New(service service.Service) *EmployeeController {
-	return &EmployeeController{service: service}
+// New parses the page template with funcs (see router.FuncMap).
+func New(service service.Service, funcs template.FuncMap) *EmployeeController {
+	tmpl := template.Must(
+		template.Must(
+			template.New("jsData").Funcs(funcs).Parse(src),
+		).ParseFiles("web/templates/employee.html"),
+	)
+	return &EmployeeController{service: service, tmpl: tmpl}
 }
 
 func (c *EmployeeController) GetEmployee(w http.ResponseWriter, r *http.Request, params router.Params) {
-	idParam := params["id"]
-	id, _ := strconv.Atoi(idParam)
+	idParam := params.ByName("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
 
-	employeeData := c.service.GetEmployeeStat(id)
+	ctx := r.Context()
+
+	employeeData, err := c.service.GetEmployeeStat(ctx, id)
+	if err != nil {
+		middleware.ServiceError(w, r, err)
+		return
+	}
 	employeeDataJ, _ := json.Marshal(employeeData)
 
-	employeeTripData := c.service.GetEmployeeTripCountByAllYears(id)
+	employeeTripData, err := c.service.GetEmployeeTripCountByAllYears(ctx, id)
+	if err != nil {
+		middleware.ServiceError(w, r, err)
+		return
+	}
 	employeeTripDataJ, _ := json.Marshal(employeeTripData)
 
 	jsData := jsData{
@@ -53,11 +69,12 @@ func (c *EmployeeController) GetEmployee(w http.ResponseWriter, r *http.Request,
 		JS:    jsData,
 	}
 
-	tmpl.ExecuteTemplate(w, "employee.html", data)
+	c.tmpl.ExecuteTemplate(w, "employee.html", data)
 }
 
 const src = `
 	<script>
+        const routes = {{routes}};
         const employeeData = {{.Table}};
         const chartData = {{.Chart}};
     </script>`
@@ -0,0 +1,74 @@
+package health_controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"TP_Andreev/internal/transport/http/router"
+)
+
+// Check is a named readiness probe of one dependency
+type Check struct {
+	Name string
+	Fn   func(ctx context.Context) error
+}
+
+type HealthController struct {
+	checks  []Check
+	timeout time.Duration
+}
+
+type componentStatus struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+type readiness struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentStatus `json:"components"`
+}
+
+// New creates a controller that runs checks on /readyz, each limited by timeout.
+func New(timeout time.Duration, checks ...Check) *HealthController {
+	return &HealthController{checks: checks, timeout: timeout}
+}
+
+// Liveness reports that the process is up and serving requests.
+func (c *HealthController) Liveness(w http.ResponseWriter, r *http.Request, params router.Params) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readiness runs every check and answers 503 if any of them fails.
+func (c *HealthController) Readiness(w http.ResponseWriter, r *http.Request, params router.Params) {
+	res := readiness{Status: "ok", Components: map[string]componentStatus{}}
+	for _, check := range c.checks {
+		ctx, cancel := context.WithTimeout(r.Context(), c.timeout)
+		start := time.Now()
+		err := check.Fn(ctx)
+		cancel()
+
+		status := componentStatus{Status: "ok", LatencyMs: time.Since(start).Milliseconds()}
+		if err != nil {
+			status.Status = "fail"
+			status.Error = err.Error()
+			res.Status = "fail"
+		}
+		res.Components[check.Name] = status
+	}
+
+	code := http.StatusOK
+	if res.Status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, res)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -6,11 +6,13 @@ import (
 	"net/http"
 
 	"TP_Andreev/internal/service"
+	"TP_Andreev/internal/transport/http/middleware"
 	"TP_Andreev/internal/transport/http/router"
 )
 
 type MainController struct {
 	service service.Service
+	tmpl    *template.Template
 }
 
 type tmplData struct {
@@ -19,24 +21,38 @@ type tmplData struct {
 	Chart2 template.JS
 }
 
-var tmpl = template.Must(
-	template.Must(
-		template.New("jsData").Parse(src),
-	).ParseFiles("web/templates/main.html"),
-)
-
-func New(service service.Service) *MainController {
-	return &MainController{service: service}
+// New parses the page template with funcs (see router.FuncMap).
+func New(service service.Service, funcs template.FuncMap) *MainController {
+	tmpl := template.Must(
+		template.Must(
+			template.New("jsData").Funcs(funcs).Parse(src),
+		).ParseFiles("web/templates/main.html"),
+	)
+	return &MainController{service: service, tmpl: tmpl}
 }
 
 func (c *MainController) GetMainPage(w http.ResponseWriter, r *http.Request, params router.Params) {
-	employeeTripsData := c.service.GetAllEmployeeTrips()
+	ctx := r.Context()
+
+	employeeTripsData, err := c.service.GetAllEmployeeTrips(ctx)
+	if err != nil {
+		middleware.ServiceError(w, r, err)
+		return
+	}
 	employeeTripsDataJ, _ := json.Marshal(employeeTripsData)
 
-	moneySpentData := c.service.GetMoneySpentByAllYears()
+	moneySpentData, err := c.service.GetMoneySpentByAllYears(ctx)
+	if err != nil {
+		middleware.ServiceError(w, r, err)
+		return
+	}
 	moneySpentDataJ, _ := json.Marshal(moneySpentData)
 
-	tripCountData := c.service.GetTripCountByAllYears()
+	tripCountData, err := c.service.GetTripCountByAllYears(ctx)
+	if err != nil {
+		middleware.ServiceError(w, r, err)
+		return
+	}
 	tripCountDataJ, _ := json.Marshal(tripCountData)
 
 	data := tmplData{
@@ -45,11 +61,12 @@ func (c *MainController) GetMainPage(w http.ResponseWriter, r *http.Request, par
 		Chart2: template.JS(tripCountDataJ),
 	}
 
-	tmpl.ExecuteTemplate(w, "main.html", data)
+	c.tmpl.ExecuteTemplate(w, "main.html", data)
 }
 
 const src = `
 	<script>
+        const routes = {{routes}};
         const tableData = {{.Table}};
         const chartData1 = {{.Chart1}};
         const chartData2 = {{.Chart2}};
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// AccessLog пишет в logger одну строку на запрос: метод, шаблон маршрута (а не
+// сырой путь), код ответа, размер тела, длительность и ID запроса. Ответы 5xx
+// пишутся с уровнем Error. Подключается снаружи роутера, внутри RequestID:
+//
+//	handler := middleware.RequestID(middleware.AccessLog(logger)(r))
+func AccessLog(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := NewResponseWriter(w)
+			next.ServeHTTP(rw, r)
+
+			level := slog.LevelInfo
+			if rw.Status() >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(r.Context(), level, "request",
+				slog.String("request_id", RequestIDFromContext(r.Context())),
+				slog.String("method", r.Method),
+				slog.String("route", rw.Route()),
+				slog.Int("status", rw.Status()),
+				slog.Int64("bytes", rw.Bytes()),
+				slog.Duration("latency", time.Since(start)),
+			)
+		})
+	}
+}
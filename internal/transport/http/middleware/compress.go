@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"TP_Andreev/internal/transport/http/router"
+)
+
+// compressibleTypes — типы содержимого, которые имеет смысл сжимать.
+// Картинки, архивы и шрифты уже сжаты.
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// encoders — пулы кодировщиков одного уровня сжатия.
+type encoders struct {
+	level int
+	gzip  sync.Pool
+	flate sync.Pool
+}
+
+// Compress сжимает ответ gzip или deflate, если клиент поддерживает хотя бы
+// один из них (Accept-Encoding, gzip предпочтительнее). Сжимаются только
+// текстовые типы; ответы без тела, частичные и уже закодированные ответы
+// передаются как есть. Сильный ETag при сжатии становится слабым: байты тела
+// отличаются от несжатого варианта.
+func Compress(level int) router.MiddlewareFunc {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+	pool := &encoders{level: level}
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params router.Params) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead {
+				next(w, r, params)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, pool: pool}
+			defer cw.Close()
+			next(cw, r, params)
+		}
+	}
+}
+
+// negotiateEncoding выбирает gzip или deflate по Accept-Encoding; "" — без
+// сжатия. Значения с q=0 считаются запрещёнными, и "*" не отменяет явный
+// запрет (RFC 9110, 12.5.3): "gzip;q=0, *" даёт deflate, а не gzip.
+func negotiateEncoding(header string) string {
+	accepted := map[string]bool{} // явно указанные кодирования и "*"
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "x-gzip" {
+			name = "gzip"
+		}
+		ok := true
+		if q, found := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); found {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				ok = false
+			}
+		}
+		accepted[name] = ok
+	}
+	for _, encoding := range []string{"gzip", "deflate"} {
+		if ok, listed := accepted[encoding]; listed {
+			if ok {
+				return encoding
+			}
+			continue
+		}
+		if accepted["*"] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressWriter решает, сжимать ли ответ, в момент отправки заголовков.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	pool        *encoders
+	wroteHeader bool
+	w           io.WriteCloser // nil, если ответ не сжимается
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	// промежуточные ответы 1xx не определяют, будет ли сжато тело
+	if status < http.StatusOK {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.wroteHeader = true
+	h := cw.Header()
+	if shouldCompress(status, h) {
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		h.Set("Content-Encoding", cw.encoding)
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		cw.w = cw.newEncoder()
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.w == nil {
+		return cw.ResponseWriter.Write(b)
+	}
+	return cw.w.Write(b)
+}
+
+// Flush отправляет клиенту уже сжатые данные.
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := cw.w.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap даёт http.ResponseController доступ к исходному ResponseWriter.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close дописывает хвост сжатого потока и возвращает кодировщик в пул.
+func (cw *compressWriter) Close() error {
+	if cw.w == nil {
+		return nil
+	}
+	err := cw.w.Close()
+	switch enc := cw.w.(type) {
+	case *gzip.Writer:
+		cw.pool.gzip.Put(enc)
+	case *flate.Writer:
+		cw.pool.flate.Put(enc)
+	}
+	cw.w = nil
+	return err
+}
+
+func (cw *compressWriter) newEncoder() io.WriteCloser {
+	if cw.encoding == "gzip" {
+		if enc, ok := cw.pool.gzip.Get().(*gzip.Writer); ok {
+			enc.Reset(cw.ResponseWriter)
+			return enc
+		}
+		enc, _ := gzip.NewWriterLevel(cw.ResponseWriter, cw.pool.level)
+		return enc
+	}
+	if enc, ok := cw.pool.flate.Get().(*flate.Writer); ok {
+		enc.Reset(cw.ResponseWriter)
+		return enc
+	}
+	enc, _ := flate.NewWriter(cw.ResponseWriter, cw.pool.level)
+	return enc
+}
+
+// shouldCompress проверяет, что у ответа есть тело подходящего типа и он ещё
+// не закодирован.
+func shouldCompress(status int, h http.Header) bool {
+	if status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	ct := h.Get("Content-Type")
+	for _, t := range compressibleTypes {
+		if strings.HasPrefix(ct, t) {
+			return true
+		}
+	}
+	return false
+}
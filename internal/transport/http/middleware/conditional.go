@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"TP_Andreev/internal/transport/http/router"
+)
+
+// VersionFunc возвращает текущую версию данных, от которых зависит ответ, и
+// время её изменения (нулевое, если данные ещё не менялись).
+type VersionFunc func(ctx context.Context) (version uint64, modified time.Time, err error)
+
+// Conditional добавляет к ответам GET и HEAD валидаторы ETag и Last-Modified,
+// построенные по версии данных, и отвечает 304 Not Modified на If-None-Match
+// и If-Modified-Since, не вызывая обработчик. ETag строится из build —
+// идентификатора сборки, одинакового у всех реплик и после перезапуска, — и
+// версии данных, поэтому после деплоя с новыми шаблонами кеш клиентов
+// сбрасывается, а между перезапусками сохраняется.
+// Если версию получить не удалось, ошибка пишется в logger, а запрос
+// обрабатывается без валидаторов.
+func Conditional(build string, version VersionFunc, logger *slog.Logger) router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params router.Params) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next(w, r, params)
+				return
+			}
+			v, modified, err := version(r.Context())
+			if err != nil {
+				logger.WarnContext(r.Context(), "data version unavailable", "error", err)
+				next(w, r, params)
+				return
+			}
+			etag := `"` + build + "-" + strconv.FormatUint(v, 36) + `"`
+			h := w.Header()
+			h.Set("ETag", etag)
+			h.Set("Cache-Control", "no-cache")
+			if !modified.IsZero() {
+				h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+			}
+			if notModified(r, etag, modified) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			next(w, r, params)
+		}
+	}
+}
+
+// notModified проверяет условные заголовки запроса (RFC 9110, 13.2.2):
+// If-None-Match важнее If-Modified-Since.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			// слабое сравнение: сжатый ответ получает W/ в Compress
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modified.Truncate(time.Second).After(t)
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"TP_Andreev/internal/transport/http/router"
+)
+
+// CORSConfig — настройки CORS. Пустой AllowedMethods означает «все методы,
+// которые роутер знает для пути»; "*" в AllowedOrigins и AllowedHeaders
+// разрешает любое значение. С AllowCredentials "*" в AllowedOrigins
+// игнорируется: источники с учётными данными перечисляются явно.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration // сколько браузер может кешировать ответ на preflight
+}
+
+// CORS добавляет заголовки CORS для разрешённых источников. Middleware нужно
+// подключать к корневому роутеру через Use: preflight-запросы OPTIONS не
+// совпадают ни с одним маршрутом и проходят только через middleware корня.
+// Список методов для preflight берётся из rt.AllowedMethods и ограничивается
+// cfg.AllowedMethods, поэтому браузер не получит разрешение на метод, которого
+// у пути нет. Неразрешённые запросы проходят дальше без заголовков CORS, и их
+// блокирует сам браузер.
+func CORS(rt *router.Router, cfg CORSConfig) router.MiddlewareFunc {
+	allowedMethods := make([]string, len(cfg.AllowedMethods))
+	for i, m := range cfg.AllowedMethods {
+		allowedMethods[i] = strings.ToUpper(m)
+	}
+	anyHeader := slices.Contains(cfg.AllowedHeaders, "*")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := ""
+	if cfg.MaxAge > 0 {
+		maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params router.Params) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next(w, r, params)
+				return
+			}
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			allowOrigin, ok := cfg.allowOrigin(origin)
+			if !ok {
+				next(w, r, params)
+				return
+			}
+
+			reqMethod := r.Header.Get("Access-Control-Request-Method")
+			if r.Method != http.MethodOptions || reqMethod == "" {
+				h.Set("Access-Control-Allow-Origin", allowOrigin)
+				if cfg.AllowCredentials {
+					h.Set("Access-Control-Allow-Credentials", "true")
+				}
+				if exposed != "" {
+					h.Set("Access-Control-Expose-Headers", exposed)
+				}
+				next(w, r, params)
+				return
+			}
+
+			// preflight
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			methods := rt.AllowedMethods(r.URL.Path)
+			if len(allowedMethods) > 0 {
+				methods = slices.DeleteFunc(methods, func(m string) bool {
+					return !slices.Contains(allowedMethods, m)
+				})
+			}
+			if !slices.Contains(methods, reqMethod) {
+				next(w, r, params)
+				return
+			}
+			reqHeaders := r.Header.Get("Access-Control-Request-Headers")
+			if !anyHeader && !headersAllowed(reqHeaders, cfg.AllowedHeaders) {
+				next(w, r, params)
+				return
+			}
+			h.Set("Access-Control-Allow-Origin", allowOrigin)
+			h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if reqHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", reqHeaders)
+			}
+			if cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if maxAge != "" {
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// allowOrigin возвращает значение Access-Control-Allow-Origin для origin.
+// "*" с учётными данными не действует: иначе любой сайт читал бы ответы от
+// имени пользователя.
+func (cfg CORSConfig) allowOrigin(origin string) (string, bool) {
+	for _, o := range cfg.AllowedOrigins {
+		switch {
+		case o == "*" && !cfg.AllowCredentials:
+			return "*", true
+		case o != "*" && strings.EqualFold(o, origin):
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// headersAllowed проверяет, что все заголовки из Access-Control-Request-Headers
+// есть в списке разрешённых (без учёта регистра).
+func headersAllowed(requested string, allowed []string) bool {
+	for _, name := range strings.Split(requested, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.ContainsFunc(allowed, func(a string) bool { return strings.EqualFold(a, name) }) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"TP_Andreev/internal/metrics"
+)
+
+// Metrics считает запросы и их длительность в metrics.HTTPRequests и
+// metrics.HTTPRequestDuration. Метка route — шаблон маршрута, а не сырой путь,
+// чтобы число рядов не зависело от параметров; для запросов без маршрута
+// (404, 405, перенаправления) она равна "unmatched". Подключается снаружи
+// роутера, как AccessLog.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := NewResponseWriter(w)
+		next.ServeHTTP(rw, r)
+
+		route := rw.Route()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := methodLabel(r.Method)
+		metrics.HTTPRequests.With(method, route, strconv.Itoa(rw.Status())).Inc()
+		metrics.HTTPRequestDuration.With(method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// methodLabel возвращает метод для метки method; нестандартные методы
+// сводятся к "other", иначе клиент мог бы плодить ряды без ограничений.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "other"
+}
@@ -0,0 +1,450 @@
+package middleware_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"TP_Andreev/internal/metrics"
+	"TP_Andreev/internal/transport/http/middleware"
+	"TP_Andreev/internal/transport/http/router"
+)
+
+func TestRecovery(t *testing.T) {
+	page := template.Must(template.New("error").Parse(`<h1>{{.Status}} {{.Title}}</h1>{{.RequestID}}`))
+
+	r := router.New()
+	r.Use(middleware.Recovery(page, slog.New(slog.DiscardHandler)))
+	r.GET("/boom", func(w http.ResponseWriter, r *http.Request, params router.Params) {
+		var p *struct{ Name string }
+		w.Write([]byte(p.Name))
+	})
+
+	before := metrics.HTTPPanics.Value()
+
+	req := httptest.NewRequest("GET", "/boom", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("X-Request-ID", "req-1")
+	rec := httptest.NewRecorder()
+	middleware.RequestID(r).ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("html: got status %d, want 500", rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "500 Internal Server Error") || !strings.Contains(body, "req-1") {
+		t.Errorf("html: unexpected body %q", body)
+	}
+
+	req = httptest.NewRequest("GET", "/boom", nil)
+	req.Header.Set("Accept", "application/json")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if rec.Code != http.StatusInternalServerError || body["error"] != "internal server error" {
+		t.Errorf("json: got %d %v", rec.Code, body)
+	}
+
+	if got := metrics.HTTPPanics.Value() - before; got != 2 {
+		t.Errorf("HTTPPanics grew by %d, want 2", got)
+	}
+}
+
+func TestRecoveryAfterResponseStarted(t *testing.T) {
+	page := template.Must(template.New("error").Parse(`ERRPAGE`))
+
+	r := router.New()
+	r.Use(middleware.Recovery(page, slog.New(slog.DiscardHandler)))
+	r.Use(middleware.Compress(gzip.DefaultCompression))
+	r.GET("/partial", func(w http.ResponseWriter, r *http.Request, params router.Params) {
+		w.Write([]byte("<html>partial"))
+		panic("boom")
+	})
+
+	for _, encoding := range []string{"", "gzip"} {
+		req := httptest.NewRequest("GET", "/partial", nil)
+		req.Header.Set("Accept", "text/html")
+		req.Header.Set("Accept-Encoding", encoding)
+		rec := httptest.NewRecorder()
+		func() {
+			defer func() {
+				if rec := recover(); rec != http.ErrAbortHandler {
+					t.Errorf("%q: got panic %v, want http.ErrAbortHandler", encoding, rec)
+				}
+			}()
+			r.ServeHTTP(rec, req)
+		}()
+		if strings.Contains(rec.Body.String(), "ERRPAGE") {
+			t.Errorf("%q: error page appended to a started response", encoding)
+		}
+	}
+}
+
+func TestRequestIDAndAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	r := router.New()
+	r.GET("/employee/:id", func(w http.ResponseWriter, r *http.Request, params router.Params) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(middleware.RequestIDFromContext(r.Context())))
+	})
+	h := middleware.RequestID(middleware.AccessLog(logger)(r))
+
+	cases := []struct {
+		path     string
+		incoming string
+		status   int
+		route    string
+	}{
+		{"/employee/5", "abc-123", http.StatusCreated, "/employee/:id"},
+		{"/employee/5", "bad id\nforged", http.StatusCreated, "/employee/:id"},
+		{"/nope", "", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		buf.Reset()
+		req := httptest.NewRequest("GET", c.path, nil)
+		if c.incoming != "" {
+			req.Header.Set("X-Request-ID", c.incoming)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		id := rec.Header().Get("X-Request-ID")
+		if id == "" || c.incoming == "abc-123" && id != "abc-123" || strings.Contains(id, " ") {
+			t.Errorf("%s: unexpected request id %q for incoming %q", c.path, id, c.incoming)
+		}
+		var line struct {
+			RequestID string `json:"request_id"`
+			Method    string `json:"method"`
+			Route     string `json:"route"`
+			Status    int    `json:"status"`
+			Bytes     int64  `json:"bytes"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatalf("%s: bad log line %q: %v", c.path, buf.String(), err)
+		}
+		if line.RequestID != id || line.Method != "GET" || line.Route != c.route || line.Status != c.status || line.Bytes != int64(rec.Body.Len()) {
+			t.Errorf("%s: unexpected log line %+v (id %q, body %d bytes)", c.path, line, id, rec.Body.Len())
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	limiter := middleware.NewRateLimiter(ctx, middleware.RateLimitConfig{
+		Rate:       0.01,
+		Burst:      2,
+		ValidToken: func(token string) bool { return token == "secret" },
+	})
+
+	r := router.New()
+	r.Group("/", limiter.Middleware()).GET("/", func(w http.ResponseWriter, r *http.Request, params router.Params) {})
+	r.GET("/free", func(w http.ResponseWriter, r *http.Request, params router.Params) {})
+
+	do := func(path, addr, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = addr
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := do("/", "10.0.0.1:1000", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d within burst: got %d", i, rec.Code)
+		}
+	}
+	rec := do("/", "10.0.0.1:2000", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("over burst: got %d, want 429", rec.Code)
+	}
+	if retry, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || retry < 1 {
+		t.Errorf("bad Retry-After %q", rec.Header().Get("Retry-After"))
+	}
+
+	if rec := do("/", "10.0.0.2:1000", ""); rec.Code != http.StatusOK {
+		t.Errorf("other ip: got %d", rec.Code)
+	}
+	if rec := do("/", "10.0.0.1:1000", "forged"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("unvalidated api token from limited ip: got %d, want 429", rec.Code)
+	}
+	if rec := do("/", "10.0.0.1:1000", "secret"); rec.Code != http.StatusOK {
+		t.Errorf("validated api token from limited ip: got %d", rec.Code)
+	}
+	if rec := do("/free", "10.0.0.1:1000", ""); rec.Code != http.StatusOK {
+		t.Errorf("route outside the group: got %d", rec.Code)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	r := router.New()
+	r.Use(middleware.CORS(r, middleware.CORSConfig{
+		AllowedOrigins:   []string{"https://spa.example"},
+		AllowedMethods:   []string{"GET", "POST", "DELETE"},
+		AllowedHeaders:   []string{"Content-Type"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           time.Minute,
+	}))
+	r.GET("/trips", func(w http.ResponseWriter, r *http.Request, params router.Params) {})
+	r.POST("/trips", func(w http.ResponseWriter, r *http.Request, params router.Params) {})
+	r.PUT("/trips", func(w http.ResponseWriter, r *http.Request, params router.Params) {})
+
+	preflight := func(origin, method, headers string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", "/trips", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		if headers != "" {
+			req.Header.Set("Access-Control-Request-Headers", headers)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := preflight("https://spa.example", "POST", "content-type")
+	h := rec.Header()
+	if rec.Code != http.StatusNoContent || h.Get("Access-Control-Allow-Origin") != "https://spa.example" {
+		t.Fatalf("preflight: got %d %v", rec.Code, h)
+	}
+	// PUT есть у маршрута, но не разрешён настройками; DELETE разрешён, но маршрута нет
+	if got := h.Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+		t.Errorf("Allow-Methods = %q", got)
+	}
+	if h.Get("Access-Control-Allow-Headers") != "content-type" || h.Get("Access-Control-Allow-Credentials") != "true" || h.Get("Access-Control-Max-Age") != "60" {
+		t.Errorf("preflight headers: %v", h)
+	}
+
+	for _, c := range []struct{ origin, method, headers string }{
+		{"https://evil.example", "POST", ""},
+		{"https://spa.example", "PUT", ""},
+		{"https://spa.example", "DELETE", ""},
+		{"https://spa.example", "POST", "X-Secret"},
+	} {
+		if rec := preflight(c.origin, c.method, c.headers); rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("%+v: preflight must be rejected, got %v", c, rec.Header())
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/trips", nil)
+	req.Header.Set("Origin", "https://spa.example")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	h = rec.Header()
+	if rec.Code != http.StatusOK || h.Get("Access-Control-Allow-Origin") != "https://spa.example" || h.Get("Access-Control-Expose-Headers") != "X-Request-ID" || h.Get("Vary") != "Origin" {
+		t.Errorf("simple request: got %d %v", rec.Code, h)
+	}
+
+	// "*" вместе с учётными данными не открывает доступ никому
+	wild := router.New()
+	wild.Use(middleware.CORS(wild, middleware.CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}))
+	wild.GET("/trips", func(w http.ResponseWriter, r *http.Request, params router.Params) {})
+	req = httptest.NewRequest("GET", "/trips", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	rec = httptest.NewRecorder()
+	wild.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("wildcard with credentials: Allow-Origin = %q", got)
+	}
+}
+
+func TestCompressAndConditional(t *testing.T) {
+	page := strings.Repeat("<p>trip</p>", 100)
+	version, modified := uint64(1), time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	calls := 0
+
+	r := router.New()
+	r.Use(middleware.Compress(gzip.BestSpeed))
+	r.GET("/", func(w http.ResponseWriter, r *http.Request, params router.Params) {
+		calls++
+		w.Write([]byte(page))
+	}, middleware.Conditional("v1", func(context.Context) (uint64, time.Time, error) { return version, modified, nil }, slog.New(slog.DiscardHandler)))
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "deflate, gzip;q=1.0, br;q=0")
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("", "")
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expected gzip response, got %v", rec.Header())
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != page {
+		t.Errorf("decompressed body mismatch: %d bytes", len(body))
+	}
+	etag := rec.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) || rec.Header().Get("Last-Modified") != "Wed, 01 May 2024 10:00:00 GMT" {
+		t.Fatalf("unexpected validators: %v", rec.Header())
+	}
+
+	if rec := get("If-None-Match", etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("If-None-Match: got %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+	if rec := get("If-Modified-Since", "Wed, 01 May 2024 10:00:00 GMT"); rec.Code != http.StatusNotModified {
+		t.Errorf("If-Modified-Since: got %d", rec.Code)
+	}
+
+	// другая реплика или перезапущенный процесс той же сборки выдаёт тот же ETag
+	replica := router.New()
+	replica.GET("/", func(w http.ResponseWriter, r *http.Request, params router.Params) {
+		w.Write([]byte(page))
+	}, middleware.Conditional("v1", func(context.Context) (uint64, time.Time, error) { return version, modified, nil }, slog.New(slog.DiscardHandler)))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	req.Header.Set("If-Modified-Since", "Wed, 01 May 2024 09:00:00 GMT")
+	rec = httptest.NewRecorder()
+	replica.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("same build on another replica: got %d, want 304", rec.Code)
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+
+	// импорт данных меняет версию, старый ETag больше не подходит
+	version, modified = 2, modified.Add(time.Hour)
+	if rec := get("If-None-Match", etag); rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("after import: got %d etag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0, identity")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != page {
+		t.Errorf("gzip;q=0 must disable compression, got %v", rec.Header())
+	}
+
+	// "*" не отменяет явный запрет gzip
+	for header, want := range map[string]string{"gzip;q=0, *": "deflate", "gzip;q=0, deflate;q=0, *": "", "*;q=0": ""} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", header)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Content-Encoding"); got != want {
+			t.Errorf("Accept-Encoding %q: got Content-Encoding %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	r := router.New()
+	r.Use(middleware.Timeout(20 * time.Millisecond))
+	r.GET("/slow/:id", func(w http.ResponseWriter, r *http.Request, params router.Params) {
+		// имитация запроса к БД, который прерывается по контексту
+		<-r.Context().Done()
+		if params.ByName("id") != "7" {
+			t.Errorf("params lost: %v", params)
+		}
+	})
+	r.GET("/fast", func(w http.ResponseWriter, r *http.Request, params router.Params) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("request context has no deadline")
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/slow/7", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("slow: got %d, want 503", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/fast", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("fast: got %d, want 200", rec.Code)
+	}
+
+	// клиент отключился: обработчик получает ошибку сервиса и ничего не пишет
+	r.GET("/gone", func(w http.ResponseWriter, r *http.Request, params router.Params) {
+		<-r.Context().Done()
+		middleware.ServiceError(w, r, r.Context().Err())
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/gone", nil).WithContext(ctx))
+	if rec.Code != middleware.StatusClientClosedRequest {
+		t.Errorf("canceled: got %d, want 499", rec.Code)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	r := router.New()
+	r.GET("/employee/:id", func(w http.ResponseWriter, r *http.Request, params router.Params) {})
+	h := middleware.Metrics(r)
+
+	ok := metrics.HTTPRequests.With("GET", "/employee/:id", "200")
+	notFound := metrics.HTTPRequests.With("GET", "unmatched", "404")
+	latency := metrics.HTTPRequestDuration.With("GET", "/employee/:id")
+	beforeOK, beforeNotFound, beforeLatency := ok.Value(), notFound.Value(), latency.Count()
+
+	for _, path := range []string{"/employee/1", "/employee/2", "/nope"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if ok.Value()-beforeOK != 2 || notFound.Value()-beforeNotFound != 1 || latency.Count()-beforeLatency != 2 {
+		t.Errorf("unexpected counters: ok +%d, 404 +%d, latency +%d",
+			ok.Value()-beforeOK, notFound.Value()-beforeNotFound, latency.Count()-beforeLatency)
+	}
+
+	var b strings.Builder
+	metrics.Default.WriteTo(&b)
+	if !strings.Contains(b.String(), `http_requests_total{method="GET",route="/employee/:id",status="200"}`) {
+		t.Errorf("exposition misses the route series:\n%s", b.String())
+	}
+
+	other := metrics.HTTPRequests.With("other", "unmatched", "405")
+	beforeOther := other.Value()
+	for _, method := range []string{"FOO", "BAR", "PURGE"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/employee/1", nil))
+	}
+	b.Reset()
+	metrics.Default.WriteTo(&b)
+	if other.Value()-beforeOther != 3 || strings.Contains(b.String(), `method="FOO"`) {
+		t.Errorf("non-standard methods must share the \"other\" label, got +%d:\n%s", other.Value()-beforeOther, b.String())
+	}
+}
+
+func TestServiceError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	middleware.ServiceError(rec, httptest.NewRequest("GET", "/", nil), errors.New("db down"))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("live request: got %d, want 500", rec.Code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec = httptest.NewRecorder()
+	middleware.ServiceError(rec, httptest.NewRequest("GET", "/", nil).WithContext(ctx), context.Canceled)
+	if rec.Body.Len() != 0 || rec.Code != http.StatusOK {
+		t.Errorf("done request: got %d %q, want nothing written", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"TP_Andreev/internal/transport/http/router"
+)
+
+// RateLimitConfig — параметры token bucket для одной группы маршрутов.
+type RateLimitConfig struct {
+	Rate    float64       // сколько запросов в секунду восполняется
+	Burst   int           // максимальный запас запросов
+	IdleTTL time.Duration // через сколько простоя bucket клиента удаляется
+
+	// ValidToken проверяет API-токен запроса. Только проверенный токен
+	// получает собственный bucket; если ValidToken не задан, клиенты
+	// различаются только по IP.
+	ValidToken func(token string) bool
+}
+
+// bucket — запас токенов одного клиента.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter ограничивает частоту запросов по клиенту: по проверенному
+// API-токену, иначе по IP. Для разных групп маршрутов создаются
+// отдельные RateLimiter со своими Rate и Burst.
+type RateLimiter struct {
+	cfg     RateLimitConfig
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewRateLimiter создаёт ограничитель и запускает фоновую очистку простаивающих
+// buckets, которая останавливается при отмене ctx.
+func NewRateLimiter(ctx context.Context, cfg RateLimitConfig) *RateLimiter {
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+	if cfg.IdleTTL <= 0 {
+		cfg.IdleTTL = 10 * time.Minute
+	}
+	l := &RateLimiter{
+		cfg:     cfg,
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+	go l.cleanup(ctx)
+	return l
+}
+
+// Middleware отвечает 429 с Retry-After, когда запас токенов клиента исчерпан.
+func (l *RateLimiter) Middleware() router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params router.Params) {
+			if ok, retry := l.allow(l.clientKey(r)); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next(w, r, params)
+		}
+	}
+}
+
+// allow забирает токен клиента key; если токенов нет, возвращает время, через
+// которое появится следующий.
+func (l *RateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.cfg.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.cfg.Burst), b.tokens+now.Sub(b.last).Seconds()*l.cfg.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if l.cfg.Rate <= 0 {
+		return false, l.cfg.IdleTTL
+	}
+	return false, time.Duration((1 - b.tokens) / l.cfg.Rate * float64(time.Second))
+}
+
+// cleanup раз в IdleTTL удаляет buckets, к которым не обращались дольше IdleTTL.
+func (l *RateLimiter) cleanup(ctx context.Context) {
+	ticker := time.NewTicker(l.cfg.IdleTTL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.evictIdle()
+		}
+	}
+}
+
+func (l *RateLimiter) evictIdle() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > l.cfg.IdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// clientKey определяет клиента: API-токен из Authorization: Bearer или
+// X-API-Token, если ValidToken его принял, иначе IP из RemoteAddr.
+// Непроверенный токен игнорируется: иначе случайный токен в каждом запросе
+// обходил бы ограничение и плодил buckets.
+func (l *RateLimiter) clientKey(r *http.Request) string {
+	if l.cfg.ValidToken != nil {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			token = r.Header.Get("X-API-Token")
+		}
+		if token != "" && l.cfg.ValidToken(token) {
+			return "token:" + token
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"encoding/json"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"TP_Andreev/internal/metrics"
+	"TP_Andreev/internal/transport/http/router"
+)
+
+// errorPage — данные для шаблона страницы ошибки.
+type errorPage struct {
+	Status    int
+	Title     string
+	RequestID string
+}
+
+// Recovery перехватывает панику в обработчике: пишет в logger стек вместе с
+// ID запроса, увеличивает metrics.HTTPPanics и отвечает 500 — страницей из
+// шаблона page для браузеров и JSON для API-клиентов. Если ответ уже начат,
+// дописывать к нему страницу ошибки нельзя: соединение обрывается через
+// http.ErrAbortHandler, чтобы клиент не принял обрезанный ответ за полный.
+func Recovery(page *template.Template, logger *slog.Logger) router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params router.Params) {
+			rw := NewResponseWriter(w)
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				// соединение уже оборвано — net/http обработает это сам
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				metrics.HTTPPanics.Inc()
+				id := RequestIDFromContext(r.Context())
+				logger.ErrorContext(r.Context(), "panic recovered",
+					slog.Any("panic", rec),
+					slog.String("request_id", id),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("stack", string(debug.Stack())),
+					slog.Bool("response_started", rw.Written()),
+				)
+				if rw.Written() {
+					panic(http.ErrAbortHandler)
+				}
+				writeError(rw, r, page, http.StatusInternalServerError, id)
+			}()
+			next(rw, r, params)
+		}
+	}
+}
+
+// writeError отвечает ошибкой в формате, который ожидает клиент.
+func writeError(w http.ResponseWriter, r *http.Request, page *template.Template, status int, id string) {
+	if page != nil && wantsHTML(r) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		page.Execute(w, errorPage{Status: status, Title: http.StatusText(status), RequestID: id})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":      strings.ToLower(http.StatusText(status)),
+		"request_id": id,
+	})
+}
+
+// wantsHTML сообщает, что клиент — браузер, а не API-клиент.
+func wantsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// ResponseWriter оборачивает http.ResponseWriter и запоминает код ответа,
+// число записанных байт тела и шаблон маршрута (router.RouteRecorder).
+type ResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+	route  string
+}
+
+// NewResponseWriter оборачивает w; если w уже *ResponseWriter, он же и
+// возвращается, чтобы не считать ответ дважды.
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	if rw, ok := w.(*ResponseWriter); ok {
+		return rw
+	}
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+func (w *ResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush нужен для потоковых ответов через http.Flusher.
+func (w *ResponseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap даёт http.ResponseController доступ к исходному ResponseWriter.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// SetRoute запоминает шаблон маршрута. Сохраняется первый шаблон: для
+// смонтированного подроутера это шаблон внешнего роутера (/admin/*mount).
+func (w *ResponseWriter) SetRoute(pattern string) {
+	if w.route == "" {
+		w.route = pattern
+	}
+}
+
+// Status возвращает код ответа; 200, если обработчик ничего не записал явно.
+func (w *ResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Bytes возвращает число записанных байт тела.
+func (w *ResponseWriter) Bytes() int64 {
+	return w.bytes
+}
+
+// Route возвращает шаблон найденного маршрута или "", если маршрут не найден.
+func (w *ResponseWriter) Route() string {
+	return w.route
+}
+
+// Written сообщает, начат ли уже ответ.
+func (w *ResponseWriter) Written() bool {
+	return w.status != 0
+}
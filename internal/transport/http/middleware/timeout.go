@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"TP_Andreev/internal/transport/http/router"
+)
+
+// StatusClientClosedRequest — нестандартный статус (как в nginx) для запросов,
+// клиент которых отключился до ответа. Пишется только в журнал и метрики:
+// клиент его уже не получит.
+const StatusClientClosedRequest = 499
+
+// Timeout ограничивает время обработки запроса: контекст запроса получает
+// дедлайн d, и запросы к БД через gorm.DB.WithContext прерываются по его
+// истечении или при отключении клиента. Обработчик выполняется в той же
+// горутине (в отличие от http.TimeoutHandler), поэтому параметры пути из пула
+// остаются валидными; если обработчик вернулся по дедлайну, ничего не
+// записав, Timeout отвечает 503, а если клиент отключился —
+// StatusClientClosedRequest, чтобы прерванный запрос не учитывался как 200.
+func Timeout(d time.Duration) router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params router.Params) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			rw := NewResponseWriter(w)
+			next(rw, r.WithContext(ctx), params)
+
+			if rw.Written() {
+				return
+			}
+			switch {
+			case errors.Is(ctx.Err(), context.DeadlineExceeded):
+				http.Error(rw, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			case errors.Is(ctx.Err(), context.Canceled):
+				rw.WriteHeader(StatusClientClosedRequest)
+			}
+		}
+	}
+}
+
+// ServiceError отвечает обработчику, получившему ошибку сервиса: 500, если
+// контекст запроса ещё жив. Если он завершён, ответ 503 или 499 запишет
+// Timeout. Саму ошибку логирует сервис.
+func ServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	if r.Context().Err() != nil {
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
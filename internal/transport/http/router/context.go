@@ -0,0 +1,53 @@
+package router
+
+import (
+	"context"
+	"net/http"
+)
+
+// paramsKey — ключ параметров пути в контексте запроса.
+type paramsKey struct{}
+
+// ParamsFromContext возвращает параметры пути, сохранённые адаптерами этого
+// пакета (WrapHandler, WrapMiddleware), или nil.
+func ParamsFromContext(ctx context.Context) Params {
+	ps, _ := ctx.Value(paramsKey{}).(Params)
+	return ps
+}
+
+// withParams кладёт копию параметров в контекст запроса. Копия нужна, потому
+// что исходный срез вернётся в пул после ответа, а контекст может жить дольше.
+// Если в контексте уже те же параметры, запрос не копируется.
+func withParams(r *http.Request, ps Params) *http.Request {
+	if len(ps) == 0 {
+		return r
+	}
+	if cur := ParamsFromContext(r.Context()); len(cur) == len(ps) && (len(cur) == 0 || &cur[0] == &ps[0]) {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), paramsKey{}, ps.Copy()))
+}
+
+// ServeHTTP делает HandlerFunc обычным http.Handler: параметры берутся из
+// контекста запроса (см. ParamsFromContext).
+func (h HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h(w, r, ParamsFromContext(r.Context()))
+}
+
+// WrapHandler превращает стандартный http.Handler в HandlerFunc. Параметры
+// пути доступны обработчику через ParamsFromContext(r.Context()).
+func WrapHandler(h http.Handler) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, params Params) {
+		h.ServeHTTP(w, withParams(r, params))
+	}
+}
+
+// WrapMiddleware превращает стандартный middleware вида
+// func(http.Handler) http.Handler (gzip, otel и т.п.) в MiddlewareFunc для
+// Router.Use. Параметры пути проходят через контекст и снова попадают в
+// аргумент params следующего обработчика.
+func WrapMiddleware(m func(http.Handler) http.Handler) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return WrapHandler(m(next))
+	}
+}
@@ -0,0 +1,92 @@
+package router
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+)
+
+// RouteInfo описывает зарегистрированный маршрут.
+type RouteInfo struct {
+	Method      string // "*" для маршрутов Mount
+	Pattern     string
+	Name        string
+	Middlewares int // middleware корня, групп и самого маршрута
+}
+
+// Routes обходит дерево и возвращает все маршруты, отсортированные по шаблону
+// и методу.
+func (rt *Router) Routes() []RouteInfo {
+	var res []RouteInfo
+	var walk func(n *node)
+	walk = func(n *node) {
+		for _, route := range n.routes {
+			res = append(res, route.info())
+		}
+		for _, c := range n.children {
+			walk(c)
+		}
+		for _, c := range n.params {
+			walk(c)
+		}
+		if n.catchAll != nil {
+			walk(n.catchAll)
+		}
+	}
+	walk(rt.tree.root)
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Pattern != res[j].Pattern {
+			return res[i].Pattern < res[j].Pattern
+		}
+		return res[i].Method < res[j].Method
+	})
+	return res
+}
+
+func (route *Route) info() RouteInfo {
+	count := len(route.middlewares)
+	for g := route.group; g != nil; g = g.parent {
+		count += len(g.middlewares)
+	}
+	return RouteInfo{
+		Method:      route.method,
+		Pattern:     route.pattern,
+		Name:        route.name,
+		Middlewares: count,
+	}
+}
+
+var debugRoutesTmpl = template.Must(template.New("routes").Parse(`<!doctype html>
+<html lang="ru">
+<head>
+    <meta charset="utf-8">
+    <title>Routes</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.8/dist/css/bootstrap.min.css" rel="stylesheet">
+</head>
+<body class="bg-light">
+    <div class="container my-5">
+        <h5 class="mb-4">Маршруты ({{len .}})</h5>
+        <table class="table table-bordered table-sm align-middle">
+            <thead>
+                <tr><th>Метод</th><th>Шаблон</th><th>Имя</th><th>Middleware</th></tr>
+            </thead>
+            <tbody>
+            {{range .}}
+                <tr><td>{{.Method}}</td><td><code>{{.Pattern}}</code></td><td>{{.Name}}</td><td>{{.Middlewares}}</td></tr>
+            {{end}}
+            </tbody>
+        </table>
+    </div>
+</body>
+</html>`))
+
+// DebugRoutesHandler отдаёт HTML-страницу со списком маршрутов роутера.
+// Страница не регистрируется автоматически, её нужно подключить явно:
+//
+//	r.GET("/debug/routes", r.DebugRoutesHandler())
+func (rt *Router) DebugRoutesHandler() HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, params Params) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		debugRoutesTmpl.Execute(w, rt.Routes())
+	}
+}
@@ -0,0 +1,30 @@
+package router
+
+// Param — один параметр пути.
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params хранит параметры пути (например :id) в порядке их следования в
+// шаблоне. Срез берётся из пула и возвращается в него после ответа, поэтому
+// параметры нельзя сохранять после возврата из обработчика — только Copy.
+type Params []Param
+
+// ByName возвращает значение параметра или "", если его нет.
+func (ps Params) ByName(name string) string {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// Copy возвращает копию параметров, которую можно хранить дольше запроса.
+func (ps Params) Copy() Params {
+	if ps == nil {
+		return nil
+	}
+	return append(Params(nil), ps...)
+}
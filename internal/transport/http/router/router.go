@@ -1,217 +1,493 @@
 package router
 
 import (
+	"fmt"
+	"html/template"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"path"
 	"strings"
 )
 
 // HandlerFunc тот же тип, что и в net/http, но мы оборачиваем для middleware.
 type HandlerFunc func(w http.ResponseWriter, r *http.Request, params Params)
 
-// Params хранит параметры пути (например :id).
-type Params map[string]string
-
 // MiddlewareFunc — функция middleware.
 type MiddlewareFunc func(HandlerFunc) HandlerFunc
 
-// node — узел для простого trie (разделён по сегментам).
-type node struct {
-	segment    string
-	children   []*node
-	paramChild *node                  // child for :param
-	catchAll   *node                  // child for *wildcard
-	handlers   map[string]HandlerFunc // method -> handler
+// Matcher проверяет значение параметра пути, например :id{int}.
+type Matcher func(value string) bool
+
+// Options — настройки обработки неканонических путей, общие для роутера и
+// всех его групп. Перенаправление выполняется, только если исходный путь не
+// найден: 301 для GET и HEAD, 308 для остальных методов.
+type Options struct {
+	// RedirectTrailingSlash перенаправляет /a/ на /a (и наоборот), если
+	// зарегистрирован только второй вариант.
+	RedirectTrailingSlash bool
+	// RedirectFixedPath перенаправляет на очищенный путь: без повторных
+	// слэшей и сегментов . и .. (//a/./b/../c -> /a/c).
+	RedirectFixedPath bool
+	// RedirectCaseInsensitive ищет путь без учёта регистра статических
+	// сегментов и перенаправляет на зарегистрированное написание.
+	RedirectCaseInsensitive bool
+	// Logger получает предупреждения о перерегистрации маршрутов и отладочные
+	// сообщения о перенаправлениях; nil — slog.Default().
+	Logger *slog.Logger
 }
 
-// Router структура маршрутизатора.
+// Router структура маршрутизатора. Группы, созданные через Group, тоже
+// являются *Router: они разделяют с родителем дерево маршрутов и Options, но
+// имеют собственный префикс и собственную цепочку middleware.
 type Router struct {
-	root             *node
-	middlewares      []MiddlewareFunc
-	notFound         http.HandlerFunc
-	methodNotAllowed http.HandlerFunc
+	*Options
+	tree        *tree
+	parent      *Router
+	prefix      string
+	middlewares []MiddlewareFunc
+}
+
+// Route — зарегистрированный маршрут.
+type Route struct {
+	tree        *tree
+	method      string
+	pattern     string
+	name        string
+	tokens      []token // разобранный шаблон
+	handler     HandlerFunc
+	middlewares []MiddlewareFunc // middleware конкретного маршрута
+	group       *Router          // группа, через которую маршрут зарегистрирован
+	chain       HandlerFunc      // handler, обёрнутый всеми middleware
 }
 
 // New создаёт новый Router.
 func New() *Router {
-	return &Router{
-		root: &node{
-			segment:  "/",
-			children: []*node{},
-			handlers: map[string]HandlerFunc{},
-		},
-		notFound: func(w http.ResponseWriter, r *http.Request) {
-			http.NotFound(w, r)
-		},
-		methodNotAllowed: func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			w.Write([]byte("405 method not allowed"))
+	rt := &Router{
+		Options: &Options{
+			RedirectTrailingSlash:   true,
+			RedirectFixedPath:       true,
+			RedirectCaseInsensitive: true,
 		},
+		tree: newTree(),
 	}
+	rt.tree.unmatched = rt.serveUnmatched
+	return rt
 }
 
-// Use добавляет middleware (в порядке вызова).
-func (rt *Router) Use(m MiddlewareFunc) {
-	rt.middlewares = append(rt.middlewares, m)
+// Use добавляет middleware (в порядке вызова). Middleware корневого роутера
+// применяются ко всем маршрутам, middleware группы — только к маршрутам,
+// зарегистрированным через эту группу и её подгруппы.
+func (rt *Router) Use(m ...MiddlewareFunc) {
+	rt.middlewares = append(rt.middlewares, m...)
+	// цепочки собираются заранее, поэтому пересобираем уже существующие маршруты
+	for _, route := range rt.tree.routes {
+		route.build()
+	}
+	if rt.parent == nil {
+		rt.tree.unmatched = wrap(rt.serveUnmatched, rt.middlewares)
+	}
+}
+
+// Matcher регистрирует именованное ограничение для параметров пути, которое
+// затем можно указать в шаблоне: `:slug{slug}`. Ограничение должно быть
+// зарегистрировано до маршрутов, которые его используют, иначе регистрация
+// такого маршрута паникует.
+func (rt *Router) Matcher(name string, m Matcher) {
+	rt.tree.matchers[name] = m
 }
 
-// Handle регистрирует обработчик для метода и пути.
-func (rt *Router) Handle(method, path string, h HandlerFunc) {
+// Group создаёт подроутер с префиксом prefix и собственными middleware.
+// Middleware родителя применяются раньше middleware группы.
+func (rt *Router) Group(prefix string, m ...MiddlewareFunc) *Router {
+	if prefix == "" || prefix[0] != '/' {
+		panic("group prefix must start with '/'")
+	}
+	return &Router{
+		Options:     rt.Options,
+		tree:        rt.tree,
+		parent:      rt,
+		prefix:      joinPath(rt.prefix, prefix),
+		middlewares: append([]MiddlewareFunc(nil), m...),
+	}
+}
+
+// Handle регистрирует обработчик для метода и пути. Дополнительные middleware
+// применяются только к этому маршруту, после middleware групп.
+func (rt *Router) Handle(method, path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
 	if path == "" || path[0] != '/' {
 		panic("path must start with '/'")
 	}
-	segments := splitPath(path)
-	cur := rt.root
-	for i, seg := range segments {
-		isParam := len(seg) > 0 && seg[0] == ':'
-		isCatchAll := len(seg) > 0 && seg[0] == '*'
+	path = joinPath(rt.prefix, path)
+	tokens := parsePattern(path)
+	cur := rt.tree.insert(tokens)
+	if cur.routes == nil {
+		cur.routes = map[string]*Route{}
+	}
+	nparams := 0
+	for _, tok := range tokens {
+		if tok.kind != staticNode {
+			nparams++
+		}
+	}
+	if nparams > rt.tree.maxParams {
+		rt.tree.maxParams = nparams
+	}
+	method = strings.ToUpper(method)
+	route := &Route{
+		tree:        rt.tree,
+		method:      method,
+		pattern:     path,
+		tokens:      tokens,
+		handler:     h,
+		middlewares: m,
+		group:       rt,
+	}
+	route.build()
+	if old, ok := cur.routes[method]; ok {
+		rt.tree.removeRoute(old)
+		if old.name != "" {
+			delete(rt.tree.names, old.name)
+		}
+		rt.logger().Warn("route replaced", "method", method, "pattern", path)
+	}
+	cur.routes[method] = route
+	rt.tree.routes = append(rt.tree.routes, route)
+	return route
+}
+
+// GET/POST/PUT/PATCH/DELETE helpers
+func (rt *Router) GET(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return rt.Handle(http.MethodGet, path, h, m...)
+}
+func (rt *Router) POST(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return rt.Handle(http.MethodPost, path, h, m...)
+}
+func (rt *Router) PUT(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return rt.Handle(http.MethodPut, path, h, m...)
+}
+func (rt *Router) PATCH(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return rt.Handle(http.MethodPatch, path, h, m...)
+}
+func (rt *Router) DELETE(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return rt.Handle(http.MethodDelete, path, h, m...)
+}
 
-		var next *node
+// Name задаёт маршруту имя, по которому URL строит ссылки на него.
+func (route *Route) Name(name string) *Route {
+	if other, ok := route.tree.names[name]; ok && other != route {
+		panic("duplicate route name " + name)
+	}
+	if route.name != "" {
+		delete(route.tree.names, route.name)
+	}
+	route.name = name
+	route.tree.names[name] = route
+	return route
+}
 
-		// catch-all can only be last
-		if isCatchAll {
-			if i != len(segments)-1 {
-				panic("catch-all must be the last segment")
-			}
-			if cur.catchAll == nil {
-				cur.catchAll = &node{segment: seg, handlers: map[string]HandlerFunc{}}
-			}
-			next = cur.catchAll
-		} else if isParam {
-			if cur.paramChild == nil {
-				cur.paramChild = &node{segment: seg, handlers: map[string]HandlerFunc{}}
+// URL строит путь именованного маршрута, подставляя параметры. Значения
+// проверяются ограничениями параметров и экранируются.
+func (rt *Router) URL(name string, params Params) (string, error) {
+	route, ok := rt.tree.names[name]
+	if !ok {
+		return "", fmt.Errorf("route %q not found", name)
+	}
+	var b strings.Builder
+	for _, tok := range route.tokens {
+		n := tok.node
+		switch tok.kind {
+		case paramNode:
+			value := params.ByName(n.paramName)
+			if value == "" {
+				return "", fmt.Errorf("route %q: missing param %q", name, n.paramName)
 			}
-			next = cur.paramChild
-		} else {
-			// static child
-			for _, c := range cur.children {
-				if c.segment == seg {
-					next = c
-					break
-				}
+			if n.matcher != nil && !n.matcher(value) {
+				return "", fmt.Errorf("route %q: param %q does not match %s", name, n.paramName, tok.text)
 			}
-			if next == nil {
-				next = &node{segment: seg, handlers: map[string]HandlerFunc{}}
-				cur.children = append(cur.children, next)
+			b.WriteString(url.PathEscape(value))
+		case catchAllNode:
+			parts := strings.Split(strings.TrimPrefix(params.ByName(n.paramName), "/"), "/")
+			for i, part := range parts {
+				parts[i] = url.PathEscape(part)
 			}
+			b.WriteString(strings.Join(parts, "/"))
+		default:
+			b.WriteString(tok.text)
 		}
-		cur = next
 	}
-	if cur.handlers == nil {
-		cur.handlers = map[string]HandlerFunc{}
+	return b.String(), nil
+}
+
+// FuncMap возвращает функции для html/template:
+//
+//	{{url "employee.show" "id" 5}} — путь именованного маршрута;
+//	{{routes}}                     — имена маршрутов и их шаблоны без ограничений
+//	                                 (например "/employee/:id") для построения ссылок в JS.
+func (rt *Router) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"url": func(name string, pairs ...any) (string, error) {
+			if len(pairs)%2 != 0 {
+				return "", fmt.Errorf("url %q: odd number of param arguments", name)
+			}
+			params := make(Params, 0, len(pairs)/2)
+			for i := 0; i < len(pairs); i += 2 {
+				params = append(params, Param{Key: fmt.Sprint(pairs[i]), Value: fmt.Sprint(pairs[i+1])})
+			}
+			return rt.URL(name, params)
+		},
+		"routes": func() map[string]string {
+			res := make(map[string]string, len(rt.tree.names))
+			for name, route := range rt.tree.names {
+				res[name] = stripConstraints(route.pattern)
+			}
+			return res
+		},
 	}
-	cur.handlers[strings.ToUpper(method)] = h
 }
 
-// GET/POST helpers
-func (rt *Router) GET(path string, h HandlerFunc)  { rt.Handle("GET", path, h) }
-func (rt *Router) POST(path string, h HandlerFunc) { rt.Handle("POST", path, h) }
+// Mount передаёт все запросы с путём prefix или prefix/... в h, убирая prefix
+// из r.URL.Path (как http.StripPrefix). h может быть другим *Router или любым
+// http.Handler; middleware этого роутера и его родителей применяются к нему
+// так же, как к обычным маршрутам. Параметры пути доступны h через
+// ParamsFromContext.
+func (rt *Router) Mount(prefix string, h http.Handler, m ...MiddlewareFunc) {
+	if prefix == "" || prefix[0] != '/' {
+		panic("mount prefix must start with '/'")
+	}
+	prefix = strings.TrimRight(prefix, "/")
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {
+		r = withParams(r, params)
+		r2 := new(http.Request)
+		*r2 = *r
+		u := *r.URL
+		u.Path = "/" + params.ByName("mount")
+		u.RawPath = ""
+		r2.URL = &u
+		h.ServeHTTP(w, r2)
+	}
+	if prefix == "" {
+		rt.Handle(methodAny, "/", handler, m...)
+	} else {
+		rt.Handle(methodAny, prefix, handler, m...)
+	}
+	rt.Handle(methodAny, prefix+"/*mount", handler, m...)
+}
 
 // ServeHTTP делает Router совместимым с net/http.
 func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 	method := r.Method
-	handler, params := rt.match(method, path)
-	if handler == nil {
-		// если путь найден, но метод нет — 405, иначе 404
-		if rt.pathExists(path) {
-			rt.methodNotAllowed(w, r)
-			return
+	ps := rt.tree.getParams()
+	route := rt.tree.lookup(method, path, ps)
+	if route == nil && method == http.MethodHead {
+		// HEAD без своего обработчика выполняет GET, но без тела ответа
+		if route = rt.tree.lookup(http.MethodGet, path, ps); route != nil {
+			w = headResponseWriter{w}
 		}
-		rt.notFound(w, r)
+	}
+	if route == nil {
+		rt.tree.putParams(ps)
+		rt.tree.unmatched(w, r, nil)
 		return
 	}
-	// применяем middleware в обратном порядке (wrap)
-	final := handler
-	for i := len(rt.middlewares) - 1; i >= 0; i-- {
-		final = rt.middlewares[i](final)
+	var params Params
+	if ps != nil {
+		params = *ps
 	}
-	final(w, r, params)
+	recordRoute(w, route.pattern)
+	route.chain(w, r, params)
+	rt.tree.putParams(ps)
 }
 
-// match ищет обработчик и собирает параметры для конкретного метода.
-func (rt *Router) match(method, path string) (HandlerFunc, Params) {
-	segments := splitPath(path)
-	cur := rt.root
-	params := Params{}
+func (rt *Router) logger() *slog.Logger {
+	if rt.Logger != nil {
+		return rt.Logger
+	}
+	return slog.Default()
+}
 
-	for i, seg := range segments {
-		// try static children first
-		var matched *node
-		for _, c := range cur.children {
-			if c.segment == seg {
-				matched = c
-				break
-			}
+// AllowedMethods возвращает отсортированный список методов, зарегистрированных
+// для пути (включая автоматические HEAD и OPTIONS), — то же, что роутер
+// отдаёт в заголовке Allow. Для неизвестного пути возвращает nil.
+func (rt *Router) AllowedMethods(path string) []string {
+	return rt.tree.allowed(path)
+}
+
+// serveUnmatched отвечает на запросы без подходящего маршрута: OPTIONS и 405,
+// если путь известен, перенаправление на канонический путь или 404.
+func (rt *Router) serveUnmatched(w http.ResponseWriter, r *http.Request, _ Params) {
+	path := r.URL.Path
+	method := r.Method
+	// если путь найден, но метод нет — OPTIONS или 405, иначе 404
+	if allowed := rt.tree.allowed(path); len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		if method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
 		}
-		if matched != nil {
-			cur = matched
-			continue
+		rt.tree.methodNotAllowed(w, r)
+		return
+	}
+	if method != http.MethodConnect {
+		if target, ok := rt.redirectPath(path); ok {
+			code := http.StatusPermanentRedirect
+			if method == http.MethodGet || method == http.MethodHead {
+				code = http.StatusMovedPermanently
+			}
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			rt.logger().DebugContext(r.Context(), "redirect to canonical path", "from", path, "to", target, "status", code)
+			http.Redirect(w, r, target, code)
+			return
 		}
-		// param child?
-		if cur.paramChild != nil {
-			paramName := strings.TrimPrefix(cur.paramChild.segment, ":")
-			params[paramName] = seg
-			cur = cur.paramChild
-			continue
+	}
+	rt.tree.notFound(w, r)
+}
+
+// redirectPath ищет канонический вариант пути, которого нет в дереве, согласно
+// Options. Возвращает false, если ни одно из включённых исправлений не помогло.
+func (rt *Router) redirectPath(p string) (string, bool) {
+	candidates := []string{p}
+	if rt.RedirectFixedPath {
+		if fixed := cleanPath(p); fixed != p {
+			candidates = append(candidates, fixed)
 		}
-		// catch-all?
-		if cur.catchAll != nil {
-			paramName := strings.TrimPrefix(cur.catchAll.segment, "*")
-			rest := strings.Join(segments[i:], "/")
-			params[paramName] = rest
-			cur = cur.catchAll
-			break
+	}
+	if rt.RedirectTrailingSlash {
+		for _, c := range candidates {
+			if c == "/" {
+				continue
+			}
+			if strings.HasSuffix(c, "/") {
+				candidates = append(candidates, strings.TrimSuffix(c, "/"))
+			} else {
+				candidates = append(candidates, c+"/")
+			}
 		}
-		// nothing matched
-		return nil, nil
 	}
-	// found node — look up handler by method
-	if cur.handlers == nil {
-		return nil, nil
+	// первый кандидат — сам путь, его уже искали
+	for _, c := range candidates[1:] {
+		if rt.tree.pathExists(c) {
+			return c, true
+		}
 	}
-	if handler, ok := cur.handlers[strings.ToUpper(method)]; ok {
-		return handler, params
+	if rt.RedirectCaseInsensitive {
+		for _, c := range candidates {
+			if buf, ok := rt.tree.root.findFold(c, nil); ok {
+				if fixed := string(buf); fixed != p {
+					return fixed, true
+				}
+			}
+		}
 	}
-	return nil, nil
+	return "", false
 }
 
-// pathExists проверяет, есть ли путь вообще (без учёта метода).
-func (rt *Router) pathExists(path string) bool {
-	segments := splitPath(path)
-	cur := rt.root
-	for i, seg := range segments {
-		var matched *node
-		for _, c := range cur.children {
-			if c.segment == seg {
-				matched = c
-				break
-			}
+// RouteRecorder реализуют обёртки ResponseWriter, которым нужен шаблон
+// найденного маршрута, например для access-лога. ServeHTTP вызывает SetRoute
+// до запуска цепочки middleware, поэтому обёртка должна быть создана снаружи
+// роутера.
+type RouteRecorder interface {
+	SetRoute(pattern string)
+}
+
+// recordRoute ищет RouteRecorder среди обёрток w (через Unwrap, как
+// http.ResponseController) и передаёт ему шаблон маршрута.
+func recordRoute(w http.ResponseWriter, pattern string) {
+	for w != nil {
+		if rr, ok := w.(RouteRecorder); ok {
+			rr.SetRoute(pattern)
+			return
 		}
-		if matched != nil {
-			cur = matched
-			continue
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
 		}
-		if cur.paramChild != nil {
-			cur = cur.paramChild
-			continue
+		w = u.Unwrap()
+	}
+}
+
+// headResponseWriter отбрасывает тело ответа для автоматического HEAD.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w headResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w headResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// build собирает цепочку middleware маршрута: сначала middleware корневого
+// роутера, затем групп от внешней к внутренней, затем самого маршрута.
+func (route *Route) build() {
+	final := wrap(route.handler, route.middlewares)
+	for g := route.group; g != nil; g = g.parent {
+		final = wrap(final, g.middlewares)
+	}
+	route.chain = final
+}
+
+// wrap применяет middleware в обратном порядке, чтобы первый оказался внешним.
+func wrap(h HandlerFunc, m []MiddlewareFunc) HandlerFunc {
+	for i := len(m) - 1; i >= 0; i-- {
+		h = m[i](h)
+	}
+	return h
+}
+
+// removeRoute убирает маршрут, перерегистрированный на тот же метод и путь.
+func (t *tree) removeRoute(route *Route) {
+	for i, r := range t.routes {
+		if r == route {
+			t.routes = append(t.routes[:i], t.routes[i+1:]...)
+			return
 		}
-		if cur.catchAll != nil {
-			cur = cur.catchAll
-			break
+	}
+}
+
+// stripConstraints убирает ограничения из шаблона: /a/:id{int} -> /a/:id.
+func stripConstraints(pattern string) string {
+	segments := splitPath(pattern)
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") {
+			if j := strings.IndexByte(seg, '{'); j >= 0 {
+				segments[i] = seg[:j]
+			}
 		}
-		_ = i
-		return false
 	}
-	return cur != nil && len(cur.handlers) > 0
+	return "/" + strings.Join(segments, "/")
 }
 
+// joinPath склеивает префикс группы и путь маршрута.
+func joinPath(prefix, path string) string {
+	if prefix == "" {
+		return path
+	}
+	if path == "/" {
+		return prefix
+	}
+	return strings.TrimRight(prefix, "/") + path
+}
+
+// splitPath делит путь на сегменты без нормализации: "/a/b/" даёт
+// ["a" "b" ""], а "//a" — ["" "a"], поэтому такие пути не совпадают с /a/b и
+// /a и обрабатываются перенаправлениями из Options.
 func splitPath(p string) []string {
 	if p == "/" || p == "" {
 		return []string{}
 	}
-	p = strings.Trim(p, "/")
+	return strings.Split(strings.TrimPrefix(p, "/"), "/")
+}
+
+// cleanPath приводит путь к каноническому виду как path.Clean, но сохраняет
+// завершающий слэш.
+func cleanPath(p string) string {
 	if p == "" {
-		return []string{}
+		return "/"
+	}
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
 	}
-	return strings.Split(p, "/")
+	return cleaned
 }
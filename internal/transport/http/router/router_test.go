@@ -0,0 +1,520 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// tag возвращает middleware, дописывающий метку в заголовок X-Trace.
+func tag(label string) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params Params) {
+			w.Header().Add("X-Trace", label)
+			next(w, r, params)
+		}
+	}
+}
+
+func ok(w http.ResponseWriter, r *http.Request, params Params) {
+	w.Write([]byte("ok"))
+}
+
+func serve(rt http.Handler, method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func TestGroupMiddlewareOrder(t *testing.T) {
+	r := New()
+	r.Use(tag("global"))
+	r.GET("/", ok)
+
+	api := r.Group("/api", tag("api"))
+	v1 := api.Group("/v1", tag("v1"))
+	v1.GET("/trips", ok, tag("route"))
+
+	// middleware, добавленный после регистрации маршрута, тоже применяется
+	api.Use(tag("api-late"))
+
+	cases := []struct {
+		path  string
+		trace string
+	}{
+		{"/", "global"},
+		{"/api/v1/trips", "global,api,api-late,v1,route"},
+	}
+	for _, c := range cases {
+		rec := serve(r, "GET", c.path)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d, want 200", c.path, rec.Code)
+		}
+		got := strings.Join(rec.Header().Values("X-Trace"), ",")
+		if got != c.trace {
+			t.Errorf("%s: got trace %q, want %q", c.path, got, c.trace)
+		}
+	}
+
+	if rec := serve(r, "GET", "/trips"); rec.Code != http.StatusNotFound {
+		t.Errorf("/trips: got status %d, want 404", rec.Code)
+	}
+}
+
+func TestUnknownMatcherPanics(t *testing.T) {
+	for _, pattern := range []string{"/employee/:id{integer}", "/employee/:slug{slug}"} {
+		func() {
+			defer func() {
+				if rec := recover(); rec == nil || !strings.Contains(fmt.Sprint(rec), "unknown matcher") {
+					t.Errorf("%s: got panic %v, want unknown matcher", pattern, rec)
+				}
+			}()
+			New().GET(pattern, func(w http.ResponseWriter, r *http.Request, params Params) {})
+		}()
+	}
+
+	// буквальный текст задаётся группой, а не голым именем
+	r := New()
+	r.GET("/report/:kind{(?:yearly)}", func(w http.ResponseWriter, r *http.Request, params Params) {})
+	if rec := serve(r, "GET", "/report/yearly"); rec.Code != http.StatusOK {
+		t.Errorf("literal constraint: got status %d", rec.Code)
+	}
+}
+
+func TestParamConstraints(t *testing.T) {
+	r := New()
+	r.Matcher("slug", func(v string) bool { return strings.Trim(v, "abcdefghijklmnopqrstuvwxyz-") == "" })
+
+	echo := func(name string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params Params) {
+			w.Write([]byte(name + "=" + params.ByName(name)))
+		}
+	}
+	r.GET("/employee/:id{int}", echo("id"))
+	r.GET("/employee/:slug{slug}", echo("slug"))
+	r.GET("/report/:year{[0-9]{4}}", echo("year"))
+	r.GET("/page/:n{uint}", echo("n"))
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/employee/42", http.StatusOK, "id=42"},
+		{"/employee/john-doe", http.StatusOK, "slug=john-doe"},
+		{"/employee/John", http.StatusNotFound, ""},
+		{"/report/2024", http.StatusOK, "year=2024"},
+		{"/report/24", http.StatusNotFound, ""},
+		{"/report/20245", http.StatusNotFound, ""},
+		{"/employee/-5", http.StatusOK, "id=-5"},
+		{"/employee/+5", http.StatusNotFound, ""},
+		{"/employee/99999999999999999999999", http.StatusNotFound, ""},
+		{"/page/18446744073709551615", http.StatusOK, "n=18446744073709551615"},
+		{"/page/-5", http.StatusNotFound, ""},
+		{"/page/18446744073709551616", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		rec := serve(r, "GET", c.path)
+		if rec.Code != c.code {
+			t.Errorf("%s: got status %d, want %d", c.path, rec.Code, c.code)
+			continue
+		}
+		if c.body != "" && rec.Body.String() != c.body {
+			t.Errorf("%s: got body %q, want %q", c.path, rec.Body.String(), c.body)
+		}
+	}
+}
+
+func TestMatchBacktracking(t *testing.T) {
+	r := New()
+	tr := r.tree
+	r.GET("/employee/export", ok)
+	r.GET("/employee/:id/trips", ok)
+	r.GET("/employee/:id{int}", ok)
+	r.POST("/employee/:name", ok)
+	r.GET("/files/static/app.js", ok)
+	r.GET("/files/*path", ok)
+	r.GET("/a/b/c", ok)
+	r.GET("/a/:x/d", ok)
+	r.GET("/a/*rest", ok)
+
+	cases := []struct {
+		method string
+		path   string
+		found  bool
+		params Params
+	}{
+		// static wins when it leads to a route
+		{"GET", "/employee/export", true, nil},
+		// static "export" is a dead end for /trips, fall back to :id
+		{"GET", "/employee/export/trips", true, Params{{Key: "id", Value: "export"}}},
+		{"GET", "/employee/7/trips", true, Params{{Key: "id", Value: "7"}}},
+		{"GET", "/employee/7", true, Params{{Key: "id", Value: "7"}}},
+		// constraint rejects, no other GET route fits
+		{"GET", "/employee/bob", false, nil},
+		// method-aware: POST is only on :name
+		{"POST", "/employee/export", true, Params{{Key: "name", Value: "export"}}},
+		{"GET", "/files/static/app.js", true, nil},
+		{"GET", "/files/static/other.js", true, Params{{Key: "path", Value: "static/other.js"}}},
+		{"GET", "/a/b/c", true, nil},
+		{"GET", "/a/b/d", true, Params{{Key: "x", Value: "b"}}},
+		{"GET", "/a/b/e", true, Params{{Key: "rest", Value: "b/e"}}},
+		{"GET", "/a", false, nil},
+	}
+	for _, c := range cases {
+		var params Params
+		route := tr.lookup(c.method, c.path, &params)
+		if (route != nil) != c.found {
+			t.Errorf("%s %s: found=%v, want %v", c.method, c.path, route != nil, c.found)
+			continue
+		}
+		if !c.found {
+			continue
+		}
+		if !slices.Equal(params, c.params) {
+			t.Errorf("%s %s: got params %v, want %v", c.method, c.path, params, c.params)
+		}
+	}
+
+	exists := []struct {
+		path string
+		want bool
+	}{
+		{"/employee/export/trips", true},
+		{"/employee/bob", true}, // only POST, still a known path
+		{"/employee/bob/trips", true},
+		{"/employee/bob/other", false},
+		{"/files/static", true},
+		{"/a", false},
+		{"/nope", false},
+	}
+	for _, c := range exists {
+		if got := tr.pathExists(c.path); got != c.want {
+			t.Errorf("pathExists(%s) = %v, want %v", c.path, got, c.want)
+		}
+	}
+}
+
+func TestURL(t *testing.T) {
+	r := New()
+	r.GET("/", ok).Name("main")
+	r.Group("/employee").GET("/:id{int}/trips/:year{[0-9]{4}}", ok).Name("employee.trips")
+	r.GET("/files/*path", ok).Name("files")
+
+	cases := []struct {
+		name    string
+		params  Params
+		want    string
+		wantErr bool
+	}{
+		{"main", nil, "/", false},
+		{"employee.trips", Params{{Key: "id", Value: "5"}, {Key: "year", Value: "2024"}}, "/employee/5/trips/2024", false},
+		{"employee.trips", Params{{Key: "id", Value: "x"}, {Key: "year", Value: "2024"}}, "", true},
+		{"employee.trips", Params{{Key: "id", Value: "5"}}, "", true},
+		{"files", Params{{Key: "path", Value: "css/a b.css"}}, "/files/css/a%20b.css", false},
+		{"missing", nil, "", true},
+	}
+	for _, c := range cases {
+		got, err := r.URL(c.name, c.params)
+		if (err != nil) != c.wantErr || got != c.want {
+			t.Errorf("URL(%s, %v) = %q, %v; want %q, err=%v", c.name, c.params, got, err, c.want, c.wantErr)
+		}
+	}
+
+	routes := r.FuncMap()["routes"].(func() map[string]string)()
+	if got := routes["employee.trips"]; got != "/employee/:id/trips/:year" {
+		t.Errorf("routes()[employee.trips] = %q", got)
+	}
+}
+
+func TestAutomaticMethods(t *testing.T) {
+	r := New()
+	r.GET("/trips/:id", ok)
+	r.DELETE("/trips/:id", ok)
+	r.PUT("/trips/export", ok)
+
+	cases := []struct {
+		method string
+		path   string
+		code   int
+		allow  string
+		body   string
+	}{
+		{"GET", "/trips/1", http.StatusOK, "", "ok"},
+		{"HEAD", "/trips/1", http.StatusOK, "", ""},
+		{"OPTIONS", "/trips/1", http.StatusNoContent, "DELETE, GET, HEAD, OPTIONS", ""},
+		{"POST", "/trips/1", http.StatusMethodNotAllowed, "DELETE, GET, HEAD, OPTIONS", "405 method not allowed"},
+		// /trips/export matches both the static and the :id node
+		{"PATCH", "/trips/export", http.StatusMethodNotAllowed, "DELETE, GET, HEAD, OPTIONS, PUT", "405 method not allowed"},
+		{"OPTIONS", "/nope", http.StatusNotFound, "", "404 page not found\n"},
+	}
+	for _, c := range cases {
+		rec := serve(r, c.method, c.path)
+		if rec.Code != c.code {
+			t.Errorf("%s %s: got status %d, want %d", c.method, c.path, rec.Code, c.code)
+		}
+		if got := rec.Header().Get("Allow"); got != c.allow {
+			t.Errorf("%s %s: got Allow %q, want %q", c.method, c.path, got, c.allow)
+		}
+		if got := rec.Body.String(); got != c.body {
+			t.Errorf("%s %s: got body %q, want %q", c.method, c.path, got, c.body)
+		}
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	r := New()
+	r.GET("/employee/:id{int}", ok)
+	r.POST("/trips", ok)
+	r.GET("/static/", ok)
+	r.GET("/Reports/:year", ok)
+
+	cases := []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{"GET", "/employee/1", http.StatusOK, ""},
+		{"GET", "/employee/1/", http.StatusMovedPermanently, "/employee/1"},
+		{"GET", "/employee/1/?tab=2", http.StatusMovedPermanently, "/employee/1?tab=2"},
+		{"GET", "//employee///1", http.StatusMovedPermanently, "/employee/1"},
+		{"GET", "/employee/../employee/1/", http.StatusMovedPermanently, "/employee/1"},
+		{"GET", "/EMPLOYEE/1", http.StatusMovedPermanently, "/employee/1"},
+		{"GET", "/reports/2024", http.StatusMovedPermanently, "/Reports/2024"},
+		{"POST", "/trips/", http.StatusPermanentRedirect, "/trips"},
+		{"GET", "/static", http.StatusMovedPermanently, "/static/"},
+		{"GET", "/employee/x/", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/", nil)
+		req.URL.Path, req.URL.RawQuery, _ = strings.Cut(c.path, "?")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != c.code {
+			t.Errorf("%s %s: got status %d, want %d", c.method, c.path, rec.Code, c.code)
+		}
+		if got := rec.Header().Get("Location"); got != c.location {
+			t.Errorf("%s %s: got Location %q, want %q", c.method, c.path, got, c.location)
+		}
+	}
+
+	strict := New()
+	strict.RedirectTrailingSlash = false
+	strict.RedirectFixedPath = false
+	strict.RedirectCaseInsensitive = false
+	strict.GET("/employee/:id", ok)
+	for _, p := range []string{"/employee/1/", "//employee/1", "/Employee/1"} {
+		if rec := serve(strict, "GET", p); rec.Code != http.StatusNotFound {
+			t.Errorf("strict %s: got status %d, want 404", p, rec.Code)
+		}
+	}
+}
+
+func TestMount(t *testing.T) {
+	echoPath := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method + " " + r.URL.Path))
+	})
+
+	admin := New()
+	admin.GET("/users/:id", func(w http.ResponseWriter, r *http.Request, params Params) {
+		w.Write([]byte("user " + params.ByName("id")))
+	})
+
+	r := New()
+	r.Use(tag("global"))
+	r.GET("/static/index.html", ok)
+	r.Mount("/static/", echoPath)
+	r.Group("/api").Mount("/admin", admin, tag("admin"))
+
+	cases := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+		trace  string
+	}{
+		{"GET", "/static/css/style.css", http.StatusOK, "GET /css/style.css", "global"},
+		{"DELETE", "/static/", http.StatusOK, "DELETE /", "global"},
+		{"GET", "/static", http.StatusOK, "GET /", "global"},
+		// a more specific route wins over the mount
+		{"GET", "/static/index.html", http.StatusOK, "ok", "global"},
+		{"GET", "/api/admin/users/7", http.StatusOK, "user 7", "global,admin"},
+		{"GET", "/api/admin/nope", http.StatusNotFound, "404 page not found\n", "global,admin"},
+		// unmatched requests still go through the global chain
+		{"GET", "/nope", http.StatusNotFound, "404 page not found\n", "global"},
+	}
+	for _, c := range cases {
+		rec := serve(r, c.method, c.path)
+		if rec.Code != c.code || rec.Body.String() != c.body {
+			t.Errorf("%s %s: got %d %q, want %d %q", c.method, c.path, rec.Code, rec.Body.String(), c.code, c.body)
+		}
+		if got := strings.Join(rec.Header().Values("X-Trace"), ","); got != c.trace {
+			t.Errorf("%s %s: got trace %q, want %q", c.method, c.path, got, c.trace)
+		}
+	}
+}
+
+func TestRadixPrefixSplit(t *testing.T) {
+	r := New()
+	echo := func(label string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params Params) {
+			w.Write([]byte(label))
+			for _, p := range params {
+				w.Write([]byte(" " + p.Key + "=" + p.Value))
+			}
+		}
+	}
+	// registration order shuffles splits of shared prefixes
+	r.GET("/employees", echo("list"))
+	r.GET("/employee/:id", echo("show"))
+	r.GET("/emp", echo("short"))
+	r.GET("/employee/:id/trips/:year", echo("trips"))
+	r.GET("/employee/export", echo("export"))
+	r.GET("/e", echo("e"))
+
+	cases := map[string]string{
+		"/employees":               "list",
+		"/employee/3":              "show id=3",
+		"/emp":                     "short",
+		"/e":                       "e",
+		"/employee/3/trips/2024":   "trips id=3 year=2024",
+		"/employee/export":         "export",
+		"/employee/export/trips/1": "trips id=export year=1",
+	}
+	for path, want := range cases {
+		rec := serve(r, "GET", path)
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("%s: got %d %q, want 200 %q", path, rec.Code, rec.Body.String(), want)
+		}
+	}
+	for _, path := range []string{"/em", "/employeesx", "/employee", "/employee/3/trips"} {
+		if rec := serve(r, "GET", path); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: got status %d, want 404", path, rec.Code)
+		}
+	}
+}
+
+// discardWriter — ResponseWriter без выделений памяти для замеров.
+type discardWriter struct{ h http.Header }
+
+func (w *discardWriter) Header() http.Header         { return w.h }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+// benchRouter повторяет таблицу маршрутов приложения и добавляет n API-маршрутов.
+func benchRouter(n int) *Router {
+	noop := func(w http.ResponseWriter, r *http.Request, params Params) {}
+	r := New()
+	r.GET("/", noop).Name("main")
+	r.GET("/employee/:id{int}", noop).Name("employee.show")
+	r.Mount("/static", http.NotFoundHandler())
+	api := r.Group("/api/v1")
+	for i := 0; i < n; i++ {
+		res := "/resource" + strconv.Itoa(i)
+		api.GET(res, noop)
+		api.GET(res+"/:id{int}", noop)
+		api.POST(res+"/:id{int}/items/:item", noop)
+	}
+	return r
+}
+
+func TestServeHTTPZeroAlloc(t *testing.T) {
+	r := benchRouter(100)
+	w := &discardWriter{h: http.Header{}}
+	for _, path := range []string{"/", "/employee/42", "/api/v1/resource57/9"} {
+		req := httptest.NewRequest("GET", path, nil)
+		if allocs := testing.AllocsPerRun(100, func() { r.ServeHTTP(w, req) }); allocs != 0 {
+			t.Errorf("%s: %v allocs per request, want 0", path, allocs)
+		}
+	}
+}
+
+func benchmarkServe(b *testing.B, method, path string) {
+	r := benchRouter(300)
+	w := &discardWriter{h: http.Header{}}
+	req := httptest.NewRequest(method, path, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(w, req)
+	}
+}
+
+func BenchmarkStatic(b *testing.B)    { benchmarkServe(b, "GET", "/") }
+func BenchmarkParam(b *testing.B)     { benchmarkServe(b, "GET", "/employee/42") }
+func BenchmarkAPIStatic(b *testing.B) { benchmarkServe(b, "GET", "/api/v1/resource299") }
+func BenchmarkAPIParams(b *testing.B) { benchmarkServe(b, "POST", "/api/v1/resource150/7/items/abc") }
+func BenchmarkMount(b *testing.B)     { benchmarkServe(b, "GET", "/static/css/style.css") }
+func BenchmarkNotFound(b *testing.B)  { benchmarkServe(b, "GET", "/api/v1/nope") }
+
+func TestStandardAdapters(t *testing.T) {
+	stdTag := func(label string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Trace", label)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	r := New()
+	r.Use(WrapMiddleware(stdTag("std")), tag("native"))
+	r.GET("/employee/:id", func(w http.ResponseWriter, r *http.Request, params Params) {
+		w.Write([]byte("native " + params.ByName("id") + " " + ParamsFromContext(r.Context()).ByName("id")))
+	})
+	r.GET("/trip/:id", WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("std " + ParamsFromContext(r.Context()).ByName("id")))
+	})))
+
+	cases := map[string]string{
+		"/employee/1": "native 1 1",
+		"/trip/2":     "std 2",
+	}
+	for path, want := range cases {
+		rec := serve(r, "GET", path)
+		if got := rec.Body.String(); got != want {
+			t.Errorf("%s: got body %q, want %q", path, got, want)
+		}
+		if got := strings.Join(rec.Header().Values("X-Trace"), ","); got != "std,native" {
+			t.Errorf("%s: got trace %q, want %q", path, got, "std,native")
+		}
+	}
+
+	// HandlerFunc is itself an http.Handler
+	var h http.Handler = HandlerFunc(ok)
+	if rec := serve(h, "GET", "/"); rec.Body.String() != "ok" {
+		t.Errorf("HandlerFunc.ServeHTTP: got body %q", rec.Body.String())
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	r := New()
+	r.Use(tag("global"))
+	r.GET("/", ok).Name("main")
+	api := r.Group("/api", tag("api"))
+	api.GET("/trips/:id{int}", ok, tag("route")).Name("trips.show")
+	api.DELETE("/trips/:id{int}", ok)
+	r.Mount("/static", http.NotFoundHandler())
+
+	want := []RouteInfo{
+		{Method: "GET", Pattern: "/", Name: "main", Middlewares: 1},
+		{Method: "DELETE", Pattern: "/api/trips/:id{int}", Middlewares: 2},
+		{Method: "GET", Pattern: "/api/trips/:id{int}", Name: "trips.show", Middlewares: 3},
+		{Method: "*", Pattern: "/static", Middlewares: 1},
+		{Method: "*", Pattern: "/static/*mount", Middlewares: 1},
+	}
+	if got := r.Routes(); !slices.Equal(got, want) {
+		t.Errorf("Routes() = %v, want %v", got, want)
+	}
+
+	r.GET("/debug/routes", r.DebugRoutesHandler())
+	body := serve(r, "GET", "/debug/routes").Body.String()
+	if !strings.Contains(body, "/api/trips/:id{int}") || !strings.Contains(body, "trips.show") {
+		t.Errorf("debug page does not list routes:\n%s", body)
+	}
+}
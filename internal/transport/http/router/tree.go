@@ -0,0 +1,458 @@
+package router
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// nodeKind — тип узла radix-дерева.
+type nodeKind uint8
+
+const (
+	staticNode   nodeKind = iota // часть пути без параметров
+	paramNode                    // :name{constraint}, ровно один непустой сегмент
+	catchAllNode                 // *name, весь остаток пути
+)
+
+// node — узел сжатого radix-дерева. Статический узел хранит общий префикс
+// путей — не обязательно целый сегмент, — а его статические дети ищутся по
+// первому байту через indices. Param- и catch-all-узлы всегда начинаются на
+// границе сегмента, то есть их родитель оканчивается на '/'.
+type node struct {
+	kind     nodeKind
+	prefix   string  // static: часть пути; param и catch-all: сегмент шаблона
+	indices  string  // первые байты prefix статических детей, в порядке children
+	children []*node // статические дети
+	params   []*node // param-дети в порядке регистрации
+	catchAll *node
+	routes   map[string]*Route // method -> route
+
+	paramName string  // имя параметра без ':'/'*' и ограничения
+	matcher   Matcher // ограничение параметра, nil — любое непустое значение
+}
+
+// token — часть шаблона маршрута: статический текст, параметр или catch-all.
+type token struct {
+	kind nodeKind
+	text string
+	node *node // узел параметра, заполняется при вставке
+}
+
+// tree — общее для роутера и всех его групп состояние.
+type tree struct {
+	root             *node
+	routes           []*Route          // все маршруты в порядке регистрации
+	names            map[string]*Route // именованные маршруты
+	matchers         map[string]Matcher
+	maxParams        int // наибольшее число параметров в одном маршруте
+	paramsPool       sync.Pool
+	notFound         http.HandlerFunc
+	methodNotAllowed http.HandlerFunc
+	unmatched        HandlerFunc // ответы без маршрута, обёрнутые middleware корня
+}
+
+// methodAny — ключ в node.routes для маршрутов, принимающих любой метод (Mount).
+const methodAny = "*"
+
+func newTree() *tree {
+	t := &tree{
+		root:  &node{kind: staticNode},
+		names: map[string]*Route{},
+		matchers: map[string]Matcher{
+			"int":   isInt,
+			"uint":  isUint,
+			"alpha": regexp.MustCompile(`^[a-zA-Z]+$`).MatchString,
+			"alnum": regexp.MustCompile(`^[a-zA-Z0-9]+$`).MatchString,
+			"uuid":  regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+		},
+		notFound: func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		},
+		methodNotAllowed: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("405 method not allowed"))
+		},
+	}
+	t.paramsPool.New = func() any {
+		ps := make(Params, 0, t.maxParams)
+		return &ps
+	}
+	return t
+}
+
+// parsePattern делит шаблон на токены: /employee/:id{int}/trips ->
+// "/employee/", ":id{int}", "/trips". Параметр занимает целый сегмент,
+// catch-all может быть только последним, пустой сегмент — только завершающим
+// слэшем (/static/).
+func parsePattern(pattern string) []token {
+	var tokens []token
+	segments := splitPath(pattern)
+	text := "/"
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		if i > 0 {
+			text += "/"
+		}
+		switch {
+		case seg == "" && !last:
+			panic("empty segment in path " + pattern)
+		case strings.HasPrefix(seg, ":"), strings.HasPrefix(seg, "*"):
+			kind := paramNode
+			if seg[0] == '*' {
+				if !last {
+					panic("catch-all must be the last segment")
+				}
+				kind = catchAllNode
+			}
+			tokens = append(tokens, token{kind: staticNode, text: text}, token{kind: kind, text: seg})
+			text = ""
+		default:
+			text += seg
+		}
+	}
+	if text != "" {
+		tokens = append(tokens, token{kind: staticNode, text: text})
+	}
+	return tokens
+}
+
+// insert добавляет токены шаблона в дерево и возвращает конечный узел.
+func (t *tree) insert(tokens []token) *node {
+	n := t.root
+	for i := range tokens {
+		tok := &tokens[i]
+		switch tok.kind {
+		case staticNode:
+			n = n.insertStatic(tok.text)
+		case paramNode:
+			var next *node
+			for _, c := range n.params {
+				if c.prefix == tok.text {
+					next = c
+					break
+				}
+			}
+			if next == nil {
+				next = t.newParamNode(tok.text)
+				n.params = append(n.params, next)
+			}
+			n = next
+		case catchAllNode:
+			if n.catchAll == nil {
+				n.catchAll = &node{kind: catchAllNode, prefix: tok.text, paramName: tok.text[1:]}
+			} else if n.catchAll.prefix != tok.text {
+				panic("conflicting catch-all " + tok.text + " and " + n.catchAll.prefix)
+			}
+			n = n.catchAll
+		}
+		tok.node = n
+	}
+	return n
+}
+
+// insertStatic спускается по статическому тексту s, разделяя узлы на общем
+// префиксе, и возвращает узел, на котором s заканчивается.
+func (n *node) insertStatic(s string) *node {
+	for s != "" {
+		i := strings.IndexByte(n.indices, s[0])
+		if i < 0 {
+			child := &node{kind: staticNode, prefix: s}
+			n.indices += s[:1]
+			n.children = append(n.children, child)
+			return child
+		}
+		c := n.children[i]
+		l := commonPrefix(c.prefix, s)
+		if l < len(c.prefix) {
+			// c.prefix длиннее общей части: хвост уезжает в новый дочерний узел
+			tail := &node{
+				kind:     staticNode,
+				prefix:   c.prefix[l:],
+				indices:  c.indices,
+				children: c.children,
+				params:   c.params,
+				catchAll: c.catchAll,
+				routes:   c.routes,
+			}
+			c.prefix = c.prefix[:l]
+			c.indices = tail.prefix[:1]
+			c.children = []*node{tail}
+			c.params = nil
+			c.catchAll = nil
+			c.routes = nil
+		}
+		n = c
+		s = s[l:]
+	}
+	return n
+}
+
+// newParamNode разбирает сегмент вида :name или :name{constraint}. Ограничение —
+// имя зарегистрированного Matcher или регулярное выражение для всего сегмента.
+// Ограничение, похожее на имя, но не зарегистрированное (опечатка или Matcher,
+// добавленный после маршрута), вызывает панику, а не становится регулярным
+// выражением; буквальный текст можно задать как (?:text).
+func (t *tree) newParamNode(seg string) *node {
+	n := &node{kind: paramNode, prefix: seg}
+	name := seg[1:]
+	if i := strings.IndexByte(name, '{'); i >= 0 {
+		if !strings.HasSuffix(name, "}") {
+			panic("unterminated constraint in segment " + seg)
+		}
+		constraint := name[i+1 : len(name)-1]
+		name = name[:i]
+		if m, ok := t.matchers[constraint]; ok {
+			n.matcher = m
+		} else if matcherName.MatchString(constraint) {
+			panic("unknown matcher " + constraint + " in segment " + seg + ": register it with Router.Matcher before the route")
+		} else {
+			re, err := regexp.Compile("^(?:" + constraint + ")$")
+			if err != nil {
+				panic("invalid constraint in segment " + seg + ": " + err.Error())
+			}
+			n.matcher = re.MatchString
+		}
+	}
+	if name == "" {
+		panic("param name is empty in segment " + seg)
+	}
+	n.paramName = name
+	return n
+}
+
+// matcherName — ограничения такого вида считаются именами Matcher.
+// isInt и isUint проверяют не только цифры, но и диапазон 64-битного
+// числа, чтобы обработчик мог разобрать значение без ошибки.
+func isInt(v string) bool {
+	_, err := strconv.ParseInt(v, 10, 64)
+	return err == nil && v[0] != '+'
+}
+
+func isUint(v string) bool {
+	_, err := strconv.ParseUint(v, 10, 64)
+	return err == nil
+}
+
+var matcherName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// lookup ищет маршрут для метода и пути, дописывая параметры в ps.
+func (t *tree) lookup(method, path string, ps *Params) *Route {
+	method = strings.ToUpper(method)
+	if found := t.root.find(path, method, ps); found != nil {
+		return found.route(method)
+	}
+	return nil
+}
+
+// pathExists проверяет, есть ли путь вообще (без учёта метода).
+func (t *tree) pathExists(path string) bool {
+	return t.root.find(path, "", nil) != nil
+}
+
+// getParams берёт из пула буфер параметров; nil, если параметров в дереве нет.
+func (t *tree) getParams() *Params {
+	if t.maxParams == 0 {
+		return nil
+	}
+	ps := t.paramsPool.Get().(*Params)
+	*ps = (*ps)[:0]
+	return ps
+}
+
+func (t *tree) putParams(ps *Params) {
+	if ps != nil {
+		t.paramsPool.Put(ps)
+	}
+}
+
+// route возвращает маршрут узла для метода с учётом methodAny.
+func (n *node) route(method string) *Route {
+	if route, ok := n.routes[method]; ok {
+		return route
+	}
+	return n.routes[methodAny]
+}
+
+// accepts сообщает, обслуживает ли узел метод; "" — любой метод.
+func (n *node) accepts(method string) bool {
+	if method == "" {
+		return len(n.routes) > 0
+	}
+	return n.route(method) != nil
+}
+
+// find ищет узел для остатка пути в порядке приоритета static > param >
+// catch-all. Если выбранная ветка не привела к узлу, который обслуживает
+// method, поиск откатывается и пробует следующую. Параметры дописываются в ps
+// (если он не nil) только для найденной ветки; функция не выделяет память.
+func (n *node) find(path, method string, ps *Params) *node {
+	if path == "" && n.accepts(method) {
+		return n
+	}
+	if path != "" {
+		// try the static child first
+		if i := strings.IndexByte(n.indices, path[0]); i >= 0 {
+			c := n.children[i]
+			if strings.HasPrefix(path, c.prefix) {
+				if found := c.find(path[len(c.prefix):], method, ps); found != nil {
+					return found
+				}
+			}
+		}
+		// then params, in registration order; a param never matches an empty segment
+		if len(n.params) > 0 {
+			end := strings.IndexByte(path, '/')
+			if end < 0 {
+				end = len(path)
+			}
+			if seg := path[:end]; seg != "" {
+				for _, c := range n.params {
+					if c.matcher != nil && !c.matcher(seg) {
+						continue
+					}
+					if ps != nil {
+						*ps = append(*ps, Param{Key: c.paramName, Value: seg})
+					}
+					if found := c.find(path[end:], method, ps); found != nil {
+						return found
+					}
+					if ps != nil {
+						*ps = (*ps)[:len(*ps)-1]
+					}
+				}
+			}
+		}
+	}
+	// catch-all takes the rest of the path
+	if c := n.catchAll; c != nil && c.accepts(method) {
+		if ps != nil {
+			*ps = append(*ps, Param{Key: c.paramName, Value: path})
+		}
+		return c
+	}
+	return nil
+}
+
+// allowed возвращает отсортированный список методов, которые обслуживаются для
+// path: объединение методов всех узлов, подходящих под путь. GET подразумевает
+// HEAD, а OPTIONS отвечается автоматически.
+func (t *tree) allowed(path string) []string {
+	set := map[string]bool{}
+	t.root.walk(path, func(n *node) {
+		for method := range n.routes {
+			if method != methodAny {
+				set[method] = true
+			}
+		}
+	})
+	if len(set) == 0 {
+		return nil
+	}
+	if set[http.MethodGet] {
+		set[http.MethodHead] = true
+	}
+	set[http.MethodOptions] = true
+	methods := make([]string, 0, len(set))
+	for method := range set {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// walk вызывает fn для каждого узла, подходящего под остаток пути.
+func (n *node) walk(path string, fn func(*node)) {
+	if path == "" {
+		fn(n)
+	} else {
+		if i := strings.IndexByte(n.indices, path[0]); i >= 0 {
+			if c := n.children[i]; strings.HasPrefix(path, c.prefix) {
+				c.walk(path[len(c.prefix):], fn)
+			}
+		}
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if seg := path[:end]; seg != "" {
+			for _, c := range n.params {
+				if c.matcher == nil || c.matcher(seg) {
+					c.walk(path[end:], fn)
+				}
+			}
+		}
+	}
+	if n.catchAll != nil {
+		fn(n.catchAll)
+	}
+}
+
+// findFold ищет зарегистрированный путь, сравнивая статические части без учёта
+// регистра ASCII, и дописывает в buf его каноническое написание. Значения
+// параметров остаются как в запросе.
+func (n *node) findFold(path string, buf []byte) ([]byte, bool) {
+	if path == "" && len(n.routes) > 0 {
+		return buf, true
+	}
+	if path != "" {
+		for _, c := range n.children {
+			if len(path) >= len(c.prefix) && equalFoldASCII(path[:len(c.prefix)], c.prefix) {
+				if res, ok := c.findFold(path[len(c.prefix):], append(buf, c.prefix...)); ok {
+					return res, true
+				}
+			}
+		}
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if seg := path[:end]; seg != "" {
+			for _, c := range n.params {
+				if c.matcher != nil && !c.matcher(seg) {
+					continue
+				}
+				if res, ok := c.findFold(path[end:], append(buf, seg...)); ok {
+					return res, true
+				}
+			}
+		}
+	}
+	if c := n.catchAll; c != nil && len(c.routes) > 0 {
+		return append(buf, path...), true
+	}
+	return nil, false
+}
+
+// commonPrefix возвращает длину общего префикса a и b.
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// equalFoldASCII сравнивает строки одинаковой длины без учёта регистра ASCII;
+// остальные байты должны совпадать точно, чтобы не сравнивать обрезанный UTF-8.
+func equalFoldASCII(a, b string) bool {
+	for i := 0; i < len(a); i++ {
+		ca, cb := a[i], b[i]
+		if ca == cb {
+			continue
+		}
+		if 'A' <= ca && ca <= 'Z' {
+			ca += 'a' - 'A'
+		}
+		if 'A' <= cb && cb <= 'Z' {
+			cb += 'a' - 'A'
+		}
+		if ca != cb {
+			return false
+		}
+	}
+	return true
+}
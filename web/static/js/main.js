@@ -13,7 +13,7 @@ function renderTable(page = 1) {
         const row = `
             <tr>
                 <td>
-                    <a class="employeeLink" href="/employee/${item.id}">
+                    <a class="employeeLink" href="${urlFor("employee.show", {id: item.id})}">
                         ${item.name}
                     </a>
                 </td>
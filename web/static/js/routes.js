@@ -0,0 +1,9 @@
+// Builds a path for a named route from the `routes` map rendered by the server,
+// e.g. urlFor("employee.show", {id: 5}) -> "/employee/5".
+function urlFor(name, params = {}) {
+    const pattern = routes[name];
+    if (pattern === undefined) {
+        throw new Error(`route ${name} not found`);
+    }
+    return pattern.replace(/[:*]([A-Za-z0-9_]+)/g, (_, key) => encodeURIComponent(params[key]));
+}